@@ -0,0 +1,207 @@
+package gosqlcrud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxBatchRows is the maximum number of rows in one multi-row INSERT, SQL Server doesn't accept more.
+const maxBatchRows = 1000
+
+// defaultMaxBatchParams is the number of bound parameters PostgreSQL and MySQL accept in a statement.
+const defaultMaxBatchParams = 65535
+
+// BatchDialect is implemented by dialects whose multi-row inserts differ from the ones of
+// CreateMany by default: INSERT INTO ... VALUES (...),(...) with up to 65535 bound parameters.
+type BatchDialect interface {
+	Dialect
+	// BatchInsertSql returns the statement inserting rows into table. columns is a comma
+	// separated list, each row a parenthesized list of placeholders.
+	BatchInsertSql(table string, columns string, rows []string) string
+	// MaxBatchParams returns the maximum number of bound parameters in a statement on conn.
+	MaxBatchParams(ctx context.Context, conn DBContext) (int, error)
+}
+
+// CreateMany - insert data into table with as few multi-row INSERT statements as possible.
+// Statements are split to stay under the parameter limit of the database. Consecutive structs
+// with the same non-nil fields share a statement. The chunks are not atomic, pass a
+// *sql.Tx as conn if they should be. Like Create, a zero single integer primary key is left
+// to the database to generate, but the generated keys are not written back. RowsAffected of
+// the returned result is the sum over all statements, LastInsertId is the one reported by the
+// last statement.
+func CreateMany[T DB, S any](conn T, data []S, table string) (*DBResult, error) {
+	return CreateManyContext(context.Background(), toDBContext(conn), data, table)
+}
+
+func CreateManyContext[T DBContext, S any](ctx context.Context, conn T, data []S, table string) (*DBResult, error) {
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return nil, errors.New("unknown database type")
+	}
+	maxParams := defaultMaxBatchParams
+	if d, ok := dbType.Dialect().(BatchDialect); ok {
+		var err error
+		if maxParams, err = d.MaxBatchParams(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+	SqlSafe(&table)
+
+	total := &DBResult{}
+	var (
+		keys []string
+		rows [][]any
+	)
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		sqlStatement, values := batchInsertStatement(dbType, table, keys, rows)
+		result, err := ExecContext(ctx, conn, sqlStatement, values...)
+		if err != nil {
+			return queryTable(err, "CreateMany", table)
+		}
+		total.RowsAffected += result.RowsAffected
+		total.LastInsertId = result.LastInsertId
+		rows = rows[:0]
+		return nil
+	}
+
+	for i := range data {
+		nonPkMap, pkMap := StructToDbMap(&data[i])
+		fieldMap := append(pkMap, nonPkMap...)
+		if genKey, _, generated := generatedKeyField(&data[i]); generated {
+			fieldMap.Delete(genKey)
+		}
+		if len(fieldMap) == 0 {
+			continue
+		}
+		rowKeys := fieldMap.Keys()
+		rowsPerStatement := min(maxBatchRows, max(1, maxParams/len(rowKeys)))
+		if !slices.Equal(keys, rowKeys) || len(rows) >= rowsPerStatement {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			keys = rowKeys
+		}
+		rows = append(rows, fieldMap.Values())
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return total, nil
+}
+
+// batchInsertStatement builds one statement inserting all rows, each row holds the values of keys.
+func batchInsertStatement(dbType DbType, table string, keys []string, rows [][]any) (string, []any) {
+	columns := strings.Join(keys, ",")
+	SqlSafe(&columns)
+	values := make([]any, 0, len(rows)*len(keys))
+	tuples := make([]string, len(rows))
+	for i, row := range rows {
+		placeholders := make([]string, len(row))
+		for j := range row {
+			placeholders[j] = GetPlaceHolder(len(values), dbType)
+			values = append(values, row[j])
+		}
+		tuples[i] = "(" + strings.Join(placeholders, ",") + ")"
+	}
+
+	if d, ok := dbType.Dialect().(BatchDialect); ok {
+		return d.BatchInsertSql(table, columns, tuples), values
+	}
+	return valuesInsertSql(table, columns, tuples), values
+}
+
+// valuesInsertSql is the standard multi-row INSERT.
+func valuesInsertSql(table string, columns string, rows []string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, columns, strings.Join(rows, ","))
+}
+
+func (d sqliteDialect) BatchInsertSql(table string, columns string, rows []string) string {
+	return valuesInsertSql(table, columns, rows)
+}
+
+// sqliteMaxParams caches the parameter limit of sqlite connections, keyed and removed like dbTypes.
+var (
+	sqliteMaxParams      = map[any]int{}
+	sqliteMaxParamsMutex sync.Mutex
+)
+
+// MaxBatchParams returns SQLITE_MAX_VARIABLE_NUMBER, which defaults to 999 before 3.32.0 and
+// to 32766 since. The version is queried once per connection.
+func (d sqliteDialect) MaxBatchParams(ctx context.Context, conn DBContext) (int, error) {
+	key, isWeak := dbTypeKey(conn)
+	if isWeak {
+		sqliteMaxParamsMutex.Lock()
+		maxParams, ok := sqliteMaxParams[key]
+		sqliteMaxParamsMutex.Unlock()
+		if ok {
+			return maxParams, nil
+		}
+	}
+	maxParams, err := sqliteVariableLimit(ctx, conn)
+	if err != nil || !isWeak {
+		return maxParams, err
+	}
+	sqliteMaxParamsMutex.Lock()
+	defer sqliteMaxParamsMutex.Unlock()
+	if _, ok := sqliteMaxParams[key]; !ok {
+		p, _ := connPointer(conn)
+		runtime.AddCleanup(p, func(key any) {
+			sqliteMaxParamsMutex.Lock()
+			defer sqliteMaxParamsMutex.Unlock()
+			delete(sqliteMaxParams, key)
+		}, key)
+	}
+	sqliteMaxParams[key] = maxParams
+	return maxParams, nil
+}
+
+// sqliteVariableLimit derives SQLITE_MAX_VARIABLE_NUMBER from the version of sqlite.
+func sqliteVariableLimit(ctx context.Context, conn DBContext) (int, error) {
+	var version string
+	if err := conn.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&version); err != nil {
+		return 0, err
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) >= 2 {
+		major, _ := strconv.Atoi(parts[0])
+		minor, _ := strconv.Atoi(parts[1])
+		if major > 3 || major == 3 && minor >= 32 {
+			return 32766, nil
+		}
+	}
+	return 999, nil
+}
+
+func (d sqlServerDialect) BatchInsertSql(table string, columns string, rows []string) string {
+	return valuesInsertSql(table, columns, rows)
+}
+
+// MaxBatchParams returns 2098: SQL Server accepts 2100 parameters, and drivers run statements
+// with sp_executesql, whose statement and parameter definitions take two of them.
+func (d sqlServerDialect) MaxBatchParams(ctx context.Context, conn DBContext) (int, error) {
+	return 2098, nil
+}
+
+// BatchInsertSql returns an INSERT ALL, oracle has no multi-row VALUES.
+func (d oracleDialect) BatchInsertSql(table string, columns string, rows []string) string {
+	var sb strings.Builder
+	sb.WriteString("INSERT ALL")
+	for _, row := range rows {
+		fmt.Fprintf(&sb, " INTO %s (%s) VALUES %s", table, columns, row)
+	}
+	sb.WriteString(" SELECT 1 FROM DUAL")
+	return sb.String()
+}
+
+func (d oracleDialect) MaxBatchParams(ctx context.Context, conn DBContext) (int, error) {
+	return defaultMaxBatchParams, nil
+}
@@ -0,0 +1,93 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestCreateMany(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = Exec(db, "CREATE TABLE test (ID INTEGER PRIMARY KEY, NAME TEXT DEFAULT 'unnamed')")
+	assert.NoError(t, err)
+
+	data := make([]Test, 50000)
+	for i := range data {
+		data[i].Id = i + 1
+		if i%10000 != 0 {
+			name := fmt.Sprintf("name%d", i+1)
+			data[i].Name = &name
+		}
+	}
+	result, err := CreateMany(db, data, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50000), result.RowsAffected)
+	assert.Equal(t, int64(50000), result.LastInsertId)
+
+	resultMaps, err := QueryToMaps(db, "SELECT COUNT(*) AS c, SUM(NAME = 'unnamed') AS unnamed FROM test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50000), resultMaps[0]["c"])
+	assert.Equal(t, int64(5), resultMaps[0]["unnamed"])
+
+	result, err = CreateMany(db, []Test{}, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.RowsAffected)
+}
+
+func TestCreateManyGeneratedKey(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = Exec(db, "CREATE TABLE test (ID INTEGER PRIMARY KEY AUTOINCREMENT, NAME TEXT)")
+	assert.NoError(t, err)
+
+	a, b := "a", "b"
+	result, err := CreateMany(db, []Test{{Name: &a}, {Name: &b}}, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.RowsAffected)
+	maps, err := QueryToMaps(db, "SELECT * FROM test ORDER BY ID")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "a"}, {"id": int64(2), "name": "b"}}, maps)
+}
+
+func TestBatchInsertStatement(t *testing.T) {
+	rows := [][]any{{1, "a"}, {2, "b"}}
+	sqlStatement, values := batchInsertStatement(PostgreSQL, "test", []string{"ID", "NAME"}, rows)
+	assert.Equal(t, "INSERT INTO test (ID,NAME) VALUES ($1,$2),($3,$4)", sqlStatement)
+	assert.Equal(t, []any{1, "a", 2, "b"}, values)
+
+	sqlStatement, _ = batchInsertStatement(Oracle, "test", []string{"ID", "NAME"}, rows)
+	assert.Equal(t, "INSERT ALL INTO test (ID,NAME) VALUES (:1,:2) INTO test (ID,NAME) VALUES (:3,:4) SELECT 1 FROM DUAL", sqlStatement)
+
+	_, ok := MySQL.Dialect().(BatchDialect)
+	assert.False(t, ok)
+	maxParams, err := SQLServer.Dialect().(BatchDialect).MaxBatchParams(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2098, maxParams)
+}
+
+func TestCreateManyMaxParams(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	_, err = Exec(db, "CREATE TABLE test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+
+	// the version of sqlite is queried once per connection
+	conn := &countingDB{DB: db}
+	_, err = CreateMany(conn, []Test{{Id: 1}}, "test")
+	assert.NoError(t, err)
+	queries := len(conn.queryRows)
+	_, err = CreateMany(conn, []Test{{Id: 2}}, "test")
+	assert.NoError(t, err)
+	assert.Len(t, conn.queryRows, queries)
+}
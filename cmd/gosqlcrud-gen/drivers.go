@@ -0,0 +1,11 @@
+package main
+
+// The drivers gosqlcrud-gen can connect with, by -driver name: mysql, pgx, sqlserver and sqlite.
+// To generate structs from another database, add the import of its driver here, and its module
+// to the go.mod of the command, e.g. _ "github.com/sijms/go-ora/v2" for oracle.
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/microsoft/go-mssqldb"
+	_ "modernc.org/sqlite"
+)
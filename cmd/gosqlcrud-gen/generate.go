@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/elgs/gosqlcrud"
+)
+
+// generate returns the formatted source of the struct for table.
+func generate(pkg string, table string, columns []gosqlcrud.Column) ([]byte, error) {
+	var fields bytes.Buffer
+	imports := map[string]bool{}
+	names := map[string]bool{}
+	for _, column := range columns {
+		goType, importPath := goType(column)
+		if importPath != "" {
+			imports[importPath] = true
+		}
+		if column.Nullable {
+			goType = "*" + goType
+		}
+		name := goName(column.Name)
+		for names[name] {
+			name += "_"
+		}
+		names[name] = true
+		tags := fmt.Sprintf(`db:"%s"`, column.Name)
+		if column.PrimaryKey > 0 {
+			tags += ` pk:"true"`
+		}
+		fmt.Fprintf(&fields, "\t%s %s `%s`\n", name, goType, tags)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by gosqlcrud-gen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(imports) > 0 {
+		src.WriteString("import (\n")
+		for _, importPath := range slices.Sorted(maps.Keys(imports)) {
+			fmt.Fprintf(&src, "\t%q\n", importPath)
+		}
+		src.WriteString(")\n\n")
+	}
+	fmt.Fprintf(&src, "// %s is a row of the table %s.\ntype %s struct {\n%s}\n", goName(table), table, goName(table), fields.String())
+	return format.Source(src.Bytes())
+}
+
+// goType returns the Go type of the values of column, and the package it needs.
+func goType(column gosqlcrud.Column) (goType string, importPath string) {
+	t := strings.ToLower(column.Type)
+	base, params, _ := strings.Cut(t, "(")
+	base = strings.TrimSpace(base)
+	contains := func(parts ...string) bool {
+		return slices.ContainsFunc(parts, func(part string) bool {
+			return strings.Contains(base, part)
+		})
+	}
+	switch {
+	case contains("char", "text", "clob", "string", "uuid", "uniqueidentifier", "enum"):
+		return "string", ""
+	case contains("json"):
+		return "json.RawMessage", "encoding/json"
+	case contains("bool") || base == "bit" || t == "tinyint(1)" || t == "number(1)":
+		return "bool", ""
+	case contains("interval", "point"):
+		return "string", ""
+	case contains("int", "serial"):
+		return "int64", ""
+	case contains("float", "double", "real"):
+		return "float64", ""
+	case contains("numeric", "decimal", "number"):
+		// NUMBER(p) and NUMBER(p,0) hold integers
+		if params != "" {
+			if _, scale, ok := strings.Cut(strings.TrimSuffix(params, ")"), ","); !ok || strings.TrimSpace(scale) == "0" {
+				return "int64", ""
+			}
+		}
+		return "float64", ""
+	case contains("money"):
+		return "float64", ""
+	case contains("date", "time"):
+		return "time.Time", "time"
+	}
+	// binary and unknown types are scanned as strings
+	return "string", ""
+}
+
+// goName returns the exported Go name of the column or table name, e.g. Id for ID and
+// CreatedAt for created_at.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var sb strings.Builder
+	for _, part := range parts {
+		// keep camel case names like createdAt, but lower all upper case ones
+		if strings.ToUpper(part) == part {
+			part = strings.ToLower(part)
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+	result := sb.String()
+	if result == "" || !unicode.IsLetter([]rune(result)[0]) {
+		result = "X" + result
+	}
+	return result
+}
+
+// fileName returns the name of the generated file of table.
+func fileName(table string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, table)
+	return name + ".go"
+}
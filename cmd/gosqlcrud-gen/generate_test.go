@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elgs/gosqlcrud"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "test.db")
+	db, err := gosqlcrud.Open("sqlite", dsn)
+	assert.NoError(t, err)
+	_, err = gosqlcrud.Exec(db, `CREATE TABLE user_account (
+		ID INTEGER PRIMARY KEY,
+		EMAIL VARCHAR(100) NOT NULL,
+		display_name TEXT,
+		SCORE REAL NOT NULL DEFAULT 0,
+		ACTIVE BOOLEAN NOT NULL,
+		CREATED_AT DATETIME,
+		SETTINGS JSON
+	)`)
+	assert.NoError(t, err)
+	_, err = gosqlcrud.Exec(db, "CREATE TABLE membership (USER_ID INTEGER NOT NULL, GROUP_ID TEXT NOT NULL, PRIMARY KEY (USER_ID, GROUP_ID))")
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	out := filepath.Join(dir, "models")
+	err = run("sqlite", dsn, "models", out, "user_account")
+	assert.NoError(t, err)
+	src, err := os.ReadFile(filepath.Join(out, "user_account.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, `// Code generated by gosqlcrud-gen. DO NOT EDIT.
+
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserAccount is a row of the table user_account.
+type UserAccount struct {
+	Id          int64            `+"`"+`db:"ID" pk:"true"`+"`"+`
+	Email       string           `+"`"+`db:"EMAIL"`+"`"+`
+	DisplayName *string          `+"`"+`db:"display_name"`+"`"+`
+	Score       float64          `+"`"+`db:"SCORE"`+"`"+`
+	Active      bool             `+"`"+`db:"ACTIVE"`+"`"+`
+	CreatedAt   *time.Time       `+"`"+`db:"CREATED_AT"`+"`"+`
+	Settings    *json.RawMessage `+"`"+`db:"SETTINGS"`+"`"+`
+}
+`, string(src))
+	_, err = os.Stat(filepath.Join(out, "membership.go"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = run("sqlite", dsn, "models", out, "")
+	assert.NoError(t, err)
+	src, err = os.ReadFile(filepath.Join(out, "membership.go"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "UserId  int64  `db:\"USER_ID\" pk:\"true\"`")
+	assert.Contains(t, string(src), "GroupId string `db:\"GROUP_ID\" pk:\"true\"`")
+
+	err = run("sqlite", dsn, "models", out, "missing")
+	assert.EqualError(t, err, "table missing not found")
+}
+
+func TestGoType(t *testing.T) {
+	for columnType, expected := range map[string]string{
+		"bigint":                      "int64",
+		"NUMBER(10)":                  "int64",
+		"NUMBER(10,2)":                "float64",
+		"NUMBER(*,0)":                 "int64",
+		"NUMBER":                      "float64",
+		"decimal(18,0)":               "int64",
+		"nvarchar(max)":               "string",
+		"datetime2(7)":                "time.Time",
+		"numeric":                     "float64",
+		"tinyint(1)":                  "bool",
+		"bit":                         "bool",
+		"character varying(255)":      "string",
+		"timestamp without time zone": "time.Time",
+		"DATETIME2":                   "time.Time",
+		"jsonb":                       "json.RawMessage",
+		"bytea":                       "string",
+	} {
+		goType, _ := goType(gosqlcrud.Column{Type: columnType})
+		assert.Equal(t, expected, goType, columnType)
+	}
+	assert.Equal(t, "Id", goName("ID"))
+	assert.Equal(t, "CreatedAt", goName("created_at"))
+	assert.Equal(t, "OrderLineItem", goName("orderLine-item"))
+	assert.Equal(t, "X2fa", goName("2FA"))
+}
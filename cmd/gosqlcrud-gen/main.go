@@ -0,0 +1,79 @@
+// Command gosqlcrud-gen writes Go structs for the tables of a database, with the db and pk tags
+// used by gosqlcrud. Nullable columns become pointer fields, so that nil values are left out
+// by Create and Update. The files are overwritten when run again.
+//
+// The command is built with the library of the same checkout, run it from a clone of the
+// repository; go install with a version is refused because of the replace in its go.mod.
+//
+// Usage:
+//
+//	gosqlcrud-gen -driver sqlite -dsn app.db -package models -out ./models [-tables users,orders]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/elgs/gosqlcrud"
+)
+
+func main() {
+	driver := flag.String("driver", "sqlite", "database/sql driver name")
+	dsn := flag.String("dsn", "", "data source name")
+	pkg := flag.String("package", "models", "package of the generated files")
+	out := flag.String("out", ".", "output directory")
+	tables := flag.String("tables", "", "comma separated tables to generate, all tables if empty")
+	flag.Parse()
+
+	if err := run(*driver, *dsn, *pkg, *out, *tables); err != nil {
+		fmt.Fprintln(os.Stderr, "gosqlcrud-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(driver string, dsn string, pkg string, out string, tableList string) error {
+	db, err := gosqlcrud.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tables, err := gosqlcrud.GetAllTables(db)
+	if err != nil {
+		return err
+	}
+	if tableList != "" {
+		wanted := strings.Split(tableList, ",")
+		for _, table := range wanted {
+			if !slices.Contains(tables, strings.TrimSpace(table)) {
+				return fmt.Errorf("table %s not found", table)
+			}
+		}
+		tables = slices.DeleteFunc(tables, func(table string) bool {
+			return !slices.ContainsFunc(wanted, func(w string) bool {
+				return strings.TrimSpace(w) == table
+			})
+		})
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		columns, err := gosqlcrud.GetTableColumns(db, table)
+		if err != nil {
+			return err
+		}
+		src, err := generate(pkg, table, columns)
+		if err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		if err := os.WriteFile(filepath.Join(out, fileName(table)), src, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
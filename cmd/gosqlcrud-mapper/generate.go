@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldKind tells how a field is scanned and stored.
+type fieldKind int
+
+const (
+	// primitiveKind fields are scanned into directly and stored as they are.
+	primitiveKind fieldKind = iota
+	// jsonKind fields are stored as JSON.
+	jsonKind
+	// unknownKind fields have a type of another package, decided at run time by gosqlcrud.
+	unknownKind
+)
+
+type field struct {
+	name    string
+	column  string
+	pk      bool
+	pointer bool
+	kind    fieldKind
+}
+
+var primitiveTypes = map[string]bool{
+	"string": true, "bool": true, "byte": true, "rune": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true,
+}
+
+// generate returns the formatted source of the mappers of types, declared in the package in dir.
+// The file named output is skipped, as it's the one being regenerated.
+func generate(dir string, types []string, output string) ([]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	pkg := ""
+	specs := map[string]*ast.TypeSpec{}
+	for _, file := range files {
+		base := filepath.Base(file)
+		if strings.HasSuffix(base, "_test.go") || base == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		pkg = f.Name.Name
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				specs[typeSpec.Name.Name] = typeSpec
+			}
+		}
+	}
+	if pkg == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by gosqlcrud-mapper. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	src.WriteString("import \"github.com/elgs/gosqlcrud\"\n\nfunc init() {\n")
+	for _, name := range types {
+		name = strings.TrimSpace(name)
+		spec, ok := specs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		structType, ok := spec.Type.(*ast.StructType)
+		if !ok || spec.TypeParams != nil {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		fields := structFields(structType, specs)
+		writeMapper(&src, name, fields)
+	}
+	src.WriteString("}\n")
+	return format.Source(src.Bytes())
+}
+
+// structFields returns the exported fields of structType with a db tag.
+func structFields(structType *ast.StructType, specs map[string]*ast.TypeSpec) []field {
+	var fields []field
+	for _, f := range structType.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tagValue, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			continue
+		}
+		tag := reflect.StructTag(tagValue)
+		column := tag.Get("db")
+		if column == "" {
+			continue
+		}
+		names := f.Names
+		if len(names) == 0 {
+			// embedded field, named after its type
+			names = []*ast.Ident{ast.NewIdent(embeddedName(f.Type))}
+		}
+		fieldType := f.Type
+		_, pointer := fieldType.(*ast.StarExpr)
+		if pointer {
+			fieldType = fieldType.(*ast.StarExpr).X
+		}
+		for _, name := range names {
+			if !name.IsExported() {
+				continue
+			}
+			fields = append(fields, field{
+				name:    name.Name,
+				column:  column,
+				pk:      tag.Get("pk") == "true",
+				pointer: pointer,
+				kind:    kindOf(fieldType, specs, map[string]bool{}),
+			})
+		}
+	}
+	return fields
+}
+
+// kindOf classifies the type expression t like gosqlcrud does by reflection. Types declared
+// in the package are resolved to their underlying type.
+func kindOf(t ast.Expr, specs map[string]*ast.TypeSpec, seen map[string]bool) fieldKind {
+	switch t := t.(type) {
+	case *ast.Ident:
+		if spec, ok := specs[t.Name]; ok && !seen[t.Name] {
+			seen[t.Name] = true
+			if spec.Assign.IsValid() {
+				return kindOf(spec.Type, specs, seen)
+			}
+			// a type defined as time.Time or a struct is not time.Time itself
+			if isStruct(spec.Type) || isTime(spec.Type) {
+				return jsonKind
+			}
+			return kindOf(spec.Type, specs, seen)
+		}
+		if primitiveTypes[t.Name] {
+			return primitiveKind
+		}
+		return jsonKind
+	case *ast.SelectorExpr:
+		if isTime(t) {
+			return primitiveKind
+		}
+		return unknownKind
+	case *ast.ParenExpr:
+		return kindOf(t.X, specs, seen)
+	}
+	return jsonKind
+}
+
+func isTime(t ast.Expr) bool {
+	selector, ok := t.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := selector.X.(*ast.Ident)
+	return ok && x.Name == "time" && selector.Sel.Name == "Time"
+}
+
+func isStruct(t ast.Expr) bool {
+	_, ok := t.(*ast.StructType)
+	return ok
+}
+
+func embeddedName(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func writeMapper(src *bytes.Buffer, name string, fields []field) {
+	fmt.Fprintf(src, "\tgosqlcrud.RegisterMapper(gosqlcrud.Mapper[%s]{\n", name)
+	src.WriteString("\t\tColumns: []string{")
+	for i, f := range fields {
+		if i > 0 {
+			src.WriteString(", ")
+		}
+		fmt.Fprintf(src, "%q", f.column)
+	}
+	src.WriteString("},\n")
+
+	fmt.Fprintf(src, "\t\tField: func(s *%s, i int) any {\n\t\t\tswitch i {\n", name)
+	for i, f := range fields {
+		target := "&s." + f.name
+		if f.kind != primitiveKind {
+			target = "gosqlcrud.ScanTarget(&s." + f.name + ")"
+		}
+		fmt.Fprintf(src, "\t\t\tcase %d:\n\t\t\t\treturn %s\n", i, target)
+	}
+	src.WriteString("\t\t\t}\n\t\t\treturn nil\n\t\t},\n")
+
+	fmt.Fprintf(src, "\t\tValues: func(s *%s, yield func(column string, pk bool, value any)) {\n", name)
+	for _, f := range fields {
+		value := "s." + f.name
+		if f.kind != primitiveKind {
+			value = "gosqlcrud.DbValue(s." + f.name + ")"
+		}
+		call := fmt.Sprintf("yield(%q, %t, %s)", f.column, f.pk, value)
+		if f.pointer {
+			fmt.Fprintf(src, "\t\t\tif s.%s != nil {\n\t\t\t\t%s\n\t\t\t}\n", f.name, call)
+		} else {
+			fmt.Fprintf(src, "\t\t\t%s\n", call)
+		}
+	}
+	src.WriteString("\t\t},\n\t})\n")
+}
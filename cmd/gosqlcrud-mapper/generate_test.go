@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const modelsSource = `package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Status string
+
+type Stamp time.Time
+
+type Address struct {
+	City string
+}
+
+type User struct {
+	Id        int64           ` + "`db:\"ID\" pk:\"true\"`" + `
+	Name      string          ` + "`db:\"NAME\"`" + `
+	Email     *string         ` + "`db:\"EMAIL\"`" + `
+	Status    Status          ` + "`db:\"STATUS\"`" + `
+	CreatedAt time.Time       ` + "`db:\"CREATED_AT\"`" + `
+	Stamp     Stamp           ` + "`db:\"STAMP\"`" + `
+	Address   *Address        ` + "`db:\"ADDRESS\"`" + `
+	Tags      []string        ` + "`db:\"TAGS\"`" + `
+	Note      sql.NullString  ` + "`db:\"NOTE\"`" + `
+	Ignored   string
+	secret    string          ` + "`db:\"SECRET\"`" + `
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "models.go"), []byte(modelsSource), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "gosqlcrud_mappers.go"), []byte("package models\n\nstale output"), 0o644))
+
+	err := run(dir, []string{"User"}, "gosqlcrud_mappers.go")
+	assert.NoError(t, err)
+	src, err := os.ReadFile(filepath.Join(dir, "gosqlcrud_mappers.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, `// Code generated by gosqlcrud-mapper. DO NOT EDIT.
+
+package models
+
+import "github.com/elgs/gosqlcrud"
+
+func init() {
+	gosqlcrud.RegisterMapper(gosqlcrud.Mapper[User]{
+		Columns: []string{"ID", "NAME", "EMAIL", "STATUS", "CREATED_AT", "STAMP", "ADDRESS", "TAGS", "NOTE"},
+		Field: func(s *User, i int) any {
+			switch i {
+			case 0:
+				return &s.Id
+			case 1:
+				return &s.Name
+			case 2:
+				return &s.Email
+			case 3:
+				return &s.Status
+			case 4:
+				return &s.CreatedAt
+			case 5:
+				return gosqlcrud.ScanTarget(&s.Stamp)
+			case 6:
+				return gosqlcrud.ScanTarget(&s.Address)
+			case 7:
+				return gosqlcrud.ScanTarget(&s.Tags)
+			case 8:
+				return gosqlcrud.ScanTarget(&s.Note)
+			}
+			return nil
+		},
+		Values: func(s *User, yield func(column string, pk bool, value any)) {
+			yield("ID", true, s.Id)
+			yield("NAME", false, s.Name)
+			if s.Email != nil {
+				yield("EMAIL", false, s.Email)
+			}
+			yield("STATUS", false, s.Status)
+			yield("CREATED_AT", false, s.CreatedAt)
+			yield("STAMP", false, gosqlcrud.DbValue(s.Stamp))
+			if s.Address != nil {
+				yield("ADDRESS", false, gosqlcrud.DbValue(s.Address))
+			}
+			yield("TAGS", false, gosqlcrud.DbValue(s.Tags))
+			yield("NOTE", false, gosqlcrud.DbValue(s.Note))
+		},
+	})
+}
+`, string(src))
+
+	_, err = generate(dir, []string{"Missing"}, "gosqlcrud_mappers.go")
+	assert.EqualError(t, err, "type Missing not found")
+	_, err = generate(dir, []string{"Status"}, "gosqlcrud_mappers.go")
+	assert.EqualError(t, err, "type Status is not a struct")
+}
@@ -0,0 +1,43 @@
+// Command gosqlcrud-mapper writes reflection-free mappers for the structs of a package, which
+// gosqlcrud uses instead of reflection once registered. Run it with go generate from the
+// package of the structs:
+//
+//	//go:generate go run github.com/elgs/gosqlcrud/cmd/gosqlcrud-mapper -type User,Order
+//
+// The mappers are written to gosqlcrud_mappers.go and registered in its init function.
+// Regenerate them whenever the db or pk tags of the structs change.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	types := flag.String("type", "", "comma separated names of the structs")
+	output := flag.String("output", "gosqlcrud_mappers.go", "output file")
+	flag.Parse()
+
+	if *types == "" {
+		fmt.Fprintln(os.Stderr, "gosqlcrud-mapper: -type is required")
+		os.Exit(2)
+	}
+	if err := run(".", strings.Split(*types, ","), *output); err != nil {
+		fmt.Fprintln(os.Stderr, "gosqlcrud-mapper:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, types []string, output string) error {
+	src, err := generate(dir, types, filepath.Base(output))
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+	return os.WriteFile(output, src, 0o644)
+}
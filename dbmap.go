@@ -0,0 +1,91 @@
+package gosqlcrud
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DbField is a column and its value.
+type DbField struct {
+	Key   string
+	Value any
+}
+
+// DbMap is an ordered list of columns and their values, so the statements built from it are
+// the same on every call. StructToDbMap returns the fields in struct field order, SortedDbMap
+// orders a plain map by column.
+type DbMap []DbField
+
+// SortedDbMap - return the entries of m sorted by key.
+func SortedDbMap(m map[string]any) DbMap {
+	dbMap := make(DbMap, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		dbMap = append(dbMap, DbField{Key: k, Value: m[k]})
+	}
+	return dbMap
+}
+
+// Get - return the value of key, and whether it's present.
+func (m DbMap) Get(key string) (any, bool) {
+	for _, f := range m {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set - set the value of key, keeping its position if it's present, appending it otherwise.
+func (m *DbMap) Set(key string, value any) {
+	for i := range *m {
+		if (*m)[i].Key == key {
+			(*m)[i].Value = value
+			return
+		}
+	}
+	*m = append(*m, DbField{Key: key, Value: value})
+}
+
+// Delete - remove key.
+func (m *DbMap) Delete(key string) {
+	*m = slices.DeleteFunc(*m, func(f DbField) bool {
+		return f.Key == key
+	})
+}
+
+// Keys - return the keys in order.
+func (m DbMap) Keys() []string {
+	keys := make([]string, len(m))
+	for i, f := range m {
+		keys[i] = f.Key
+	}
+	return keys
+}
+
+// Values - return the values in order.
+func (m DbMap) Values() []any {
+	values := make([]any, len(m))
+	for i, f := range m {
+		values[i] = f.Value
+	}
+	return values
+}
+
+// Map - return the entries as a plain map.
+func (m DbMap) Map() map[string]any {
+	result := make(map[string]any, len(m))
+	for _, f := range m {
+		result[f.Key] = f.Value
+	}
+	return result
+}
+
+func (m DbMap) String() string {
+	entries := make([]string, len(m))
+	for i, f := range m {
+		entries[i] = fmt.Sprintf("%s:%v", f.Key, f.Value)
+	}
+	return "map[" + strings.Join(entries, " ") + "]"
+}
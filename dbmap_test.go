@@ -0,0 +1,56 @@
+package gosqlcrud
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDbMap(t *testing.T) {
+	type Order struct {
+		Zone    string  `db:"ZONE"`
+		Id      int     `db:"ID" pk:"true"`
+		Amount  float64 `db:"AMOUNT"`
+		Comment *string `db:"COMMENT"`
+		Branch  string  `db:"BRANCH" pk:"true"`
+		Area    string  `db:"AREA"`
+	}
+	order := Order{Zone: "z", Id: 7, Amount: 1.5, Branch: "b", Area: "a"}
+
+	// the statements are the same on every call, in struct field order
+	for range 20 {
+		nonPkMap, pkMap := StructToDbMap(&order)
+		assert.Equal(t, DbMap{{"ZONE", "z"}, {"AMOUNT", 1.5}, {"AREA", "a"}}, nonPkMap)
+		assert.Equal(t, DbMap{{"ID", 7}, {"BRANCH", "b"}}, pkMap)
+
+		placeholders, keys, values, err := MapForSqlInsert(append(pkMap, nonPkMap...), PostgreSQL)
+		assert.NoError(t, err)
+		assert.Equal(t, "$1,$2,$3,$4,$5", placeholders)
+		assert.Equal(t, "ID,BRANCH,ZONE,AMOUNT,AREA", keys)
+		assert.Equal(t, []any{7, "b", "z", 1.5, "a"}, values)
+
+		set, values, err := MapForSqlUpdate(nonPkMap, PostgreSQL)
+		assert.NoError(t, err)
+		assert.Equal(t, "ZONE=$1,AMOUNT=$2,AREA=$3", set)
+		assert.Equal(t, []any{"z", 1.5, "a"}, values)
+
+		where, values, err := MapForSqlWhere(pkMap, 3, PostgreSQL)
+		assert.NoError(t, err)
+		assert.Equal(t, "AND ID=$4 AND BRANCH=$5", where)
+		assert.Equal(t, []any{7, "b"}, values)
+	}
+
+	m := SortedDbMap(map[string]any{"b": 2, "c": 3, "a": 1})
+	assert.Equal(t, []string{"a", "b", "c"}, m.Keys())
+	m.Set("b", 20)
+	m.Set("d", 4)
+	m.Delete("a")
+	assert.Equal(t, DbMap{{"b", 20}, {"c", 3}, {"d", 4}}, m)
+	v, ok := m.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+	_, ok = m.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]any{"b": 20, "c": 3, "d": 4}, m.Map())
+	assert.Equal(t, "map[b:20 c:3 d:4]", m.String())
+}
@@ -0,0 +1,129 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// dbTypes caches the database type of connections. Pointer connections, like *sql.DB, *sql.Tx,
+// *sql.Conn or the *sqlx.DB of other libraries, are keyed by weak pointers and their entries are
+// removed once the connection is garbage collected. Other connection types are only present if
+// bound with WithDialect, until UnbindDialect. Connection wrappers of this package share the
+// entry of the connection they wrap.
+var dbTypes = map[any]DbType{}
+var mutex = sync.RWMutex{}
+
+// dbTypeKey returns the cache key of conn, and whether the key is a weak pointer.
+func dbTypeKey(conn any) (any, bool) {
+	p, ok := connPointer(conn)
+	if ok {
+		return weak.Make(p), true
+	}
+	conn = unwrapConn(conn)
+	if conn == nil || !reflect.ValueOf(conn).Comparable() {
+		return nil, false
+	}
+	return conn, false
+}
+
+// connPointer returns the address of the innermost connection of conn if it's a pointer, as
+// a *byte so weak pointers and cleanups can be made for any connection type. Pointers to
+// zero-size values are left out, they may share their address.
+func connPointer(conn any) (*byte, bool) {
+	v := reflect.ValueOf(unwrapConn(conn))
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Type().Elem().Size() == 0 {
+		return nil, false
+	}
+	return (*byte)(v.UnsafePointer()), true
+}
+
+// unwrapAdapter returns the DB adapted by toDBContext.
+func unwrapAdapter(conn any) any {
+	if a, ok := conn.(dbAdapter); ok {
+		return a.DB
+	}
+	return conn
+}
+
+func cachedDbType(conn any) (DbType, bool) {
+	key, _ := dbTypeKey(conn)
+	if key == nil {
+		return Unknown, false
+	}
+	mutex.RLock()
+	defer mutex.RUnlock()
+	dbType, ok := dbTypes[key]
+	return dbType, ok
+}
+
+// cacheDbType remembers dbType for the lifetime of conn. Nothing is cached for connection
+// types that can't be tracked.
+func cacheDbType(conn any, dbType DbType) {
+	key, isWeak := dbTypeKey(conn)
+	if !isWeak {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := dbTypes[key]; !ok {
+		p, _ := connPointer(conn)
+		runtime.AddCleanup(p, removeDbType, key)
+	}
+	dbTypes[key] = dbType
+}
+
+// bindDbType is cacheDbType, except that connections that can't be tracked are held until uncacheDbType.
+func bindDbType(conn any, dbType DbType) {
+	key, isWeak := dbTypeKey(conn)
+	if isWeak {
+		cacheDbType(conn, dbType)
+		return
+	}
+	if key == nil {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	dbTypes[key] = dbType
+}
+
+func uncacheDbType(conn any) {
+	if key, _ := dbTypeKey(conn); key != nil {
+		removeDbType(key)
+	}
+}
+
+func removeDbType(key any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(dbTypes, key)
+}
+
+// TxBeginner is implemented by *sql.DB and *sql.Conn.
+type TxBeginner interface {
+	DBContext
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Begin - start a transaction on db. The transaction has the database type of db, so it's
+// never probed.
+func Begin(db TxBeginner) (*sql.Tx, error) {
+	return BeginTx(context.Background(), db, nil)
+}
+
+// BeginTx - same as Begin, with a context and transaction options.
+func BeginTx(ctx context.Context, db TxBeginner, opts *sql.TxOptions) (*sql.Tx, error) {
+	dbType := GetDbTypeContext(ctx, db)
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if dbType != Unknown {
+		cacheDbType(tx, dbType)
+	}
+	return tx, nil
+}
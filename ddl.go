@@ -0,0 +1,355 @@
+package gosqlcrud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DDLDialect is implemented by dialects that support CreateTableSQL.
+type DDLDialect interface {
+	Dialect
+	// ColumnType returns the column type for values of the Go type t, which is never a
+	// pointer. Types other than time.Time and the basic kinds are stored as JSON. size is the
+	// size tag of the field, 0 if not set. key tells if the column is part of a primary key,
+	// a unique constraint or an index, some databases can't index unbounded types.
+	ColumnType(t reflect.Type, size int, key bool) (string, error)
+	// IdentityType returns the type of a generated integer primary key column.
+	IdentityType() string
+}
+
+// defaultKeySize is the size of string columns without a size tag that are part of a key.
+const defaultKeySize = 255
+
+// CreateTableSQL - build the statements creating table for the struct S: a CREATE TABLE,
+// followed by a CREATE INDEX for every index. Fields with a db tag become columns,
+// pointer fields are nullable, the others NOT NULL. Fields tagged pk:"true" form the primary
+// key, a single integer key is generated by the database, as expected by Create. Optional tags:
+//
+//	size:"100"         the length of a string column
+//	default:"0"        the default value, an SQL expression
+//	unique:"true"      a unique index on the column, fields with the same name, as in
+//	                   unique:"uq_name", share a composite unique index
+//	index:"true"       same as unique, for a non-unique index
+func CreateTableSQL[S any](dbType DbType, table string) ([]string, error) {
+	return createTableSQL(dbType, reflect.TypeOf((*S)(nil)).Elem(), table)
+}
+
+func createTableSQL(dbType DbType, structType reflect.Type, table string) ([]string, error) {
+	dialect, ok := dbType.Dialect().(DDLDialect)
+	if !ok {
+		return nil, errors.New("unsupported database type")
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, errors.New("not a struct")
+	}
+	SqlSafe(&table)
+
+	type index struct {
+		name    string
+		unique  bool
+		columns []string
+	}
+	var (
+		columns []string
+		pkKeys  []string
+		indexes []*index
+	)
+	indexByName := map[string]*index{}
+	addIndex := func(tag string, unique bool, column string) {
+		prefix := "idx"
+		if unique {
+			prefix = "uq"
+		}
+		name := tag
+		if tag == "true" {
+			name = fmt.Sprintf("%s_%s_%s", prefix, table, column)
+		}
+		SqlSafe(&name)
+		idx, ok := indexByName[name]
+		if !ok {
+			idx = &index{name: name, unique: unique}
+			indexByName[name] = idx
+			indexes = append(indexes, idx)
+		}
+		idx.columns = append(idx.columns, column)
+	}
+
+	fields := dbFields(structType)
+	pkCount := 0
+	for _, field := range fields {
+		if field.Tag.Get("pk") == "true" {
+			pkCount++
+		}
+	}
+	for _, field := range fields {
+		isPk := field.Tag.Get("pk") == "true"
+		identity := isPk && pkCount == 1 && isIntKind(derefType(field.Type).Kind())
+		column, columnType, err := fieldColumnType(dialect, field, identity)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column+" "+columnType+columnConstraints(field, true))
+
+		uniqueTag, indexTag := field.Tag.Get("unique"), field.Tag.Get("index")
+		if isPk {
+			pkKeys = append(pkKeys, column)
+		}
+		if isTagSet(uniqueTag) {
+			addIndex(uniqueTag, true, column)
+		}
+		if isTagSet(indexTag) {
+			addIndex(indexTag, false, column)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no db fields")
+	}
+	if len(pkKeys) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pkKeys, ", ")))
+	}
+
+	statements := []string{fmt.Sprintf("CREATE TABLE %s (%s)", table, strings.Join(columns, ", "))}
+	for _, idx := range indexes {
+		create := "CREATE INDEX"
+		if idx.unique {
+			create = "CREATE UNIQUE INDEX"
+		}
+		statements = append(statements, fmt.Sprintf("%s %s ON %s (%s)", create, idx.name, table, strings.Join(idx.columns, ", ")))
+	}
+	return statements, nil
+}
+
+// dbFields returns the exported fields of structType with a db tag.
+func dbFields(structType reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.IsExported() && field.Tag.Get("db") != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// fieldColumnType returns the column name and type of field. identity tells if the field is
+// a generated primary key.
+func fieldColumnType(dialect DDLDialect, field reflect.StructField, identity bool) (column string, columnType string, err error) {
+	column = field.Tag.Get("db")
+	SqlSafe(&column)
+	if identity {
+		return column, dialect.IdentityType(), nil
+	}
+	size := 0
+	if sizeTag := field.Tag.Get("size"); sizeTag != "" {
+		if size, err = strconv.Atoi(sizeTag); err != nil || size <= 0 {
+			return "", "", fmt.Errorf("invalid size of %s: %s", column, sizeTag)
+		}
+	}
+	key := field.Tag.Get("pk") == "true" || isTagSet(field.Tag.Get("unique")) || isTagSet(field.Tag.Get("index"))
+	if columnType, err = dialect.ColumnType(derefType(field.Type), size, key); err != nil {
+		return "", "", fmt.Errorf("%s: %w", column, err)
+	}
+	return column, columnType, nil
+}
+
+// columnConstraints returns the DEFAULT and NOT NULL clauses of the column of field. Without
+// notNull, the column is only NOT NULL if it has a default.
+func columnConstraints(field reflect.StructField, notNull bool) string {
+	var constraints string
+	defaultTag := field.Tag.Get("default")
+	if defaultTag != "" {
+		constraints += " DEFAULT " + defaultTag
+	}
+	required := field.Type.Kind() != reflect.Pointer || field.Tag.Get("pk") == "true"
+	if required && (notNull || defaultTag != "") {
+		constraints += " NOT NULL"
+	}
+	return constraints
+}
+
+func isTagSet(tag string) bool {
+	return tag != "" && tag != "false"
+}
+
+// CreateTable - create table for the struct S, see CreateTableSQL. The statements are not
+// run in a transaction, pass a *sql.Tx as conn if the database supports transactional DDL.
+func CreateTable[S any, T DB](conn T, table string) error {
+	return CreateTableContext[S](context.Background(), toDBContext(conn), table)
+}
+
+func CreateTableContext[S any, T DBContext](ctx context.Context, conn T, table string) error {
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return errors.New("unknown database type")
+	}
+	statements, err := CreateTableSQL[S](dbType, table)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := ExecContext(ctx, conn, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnTypes are the column types of a dialect by kind of Go type.
+type columnTypes struct {
+	boolean  string
+	smallInt string
+	bigInt   string
+	float32  string
+	float64  string
+	text     string // unbounded string
+	varchar  string // format of a string with a size
+	time     string
+	json     string
+}
+
+func (c columnTypes) columnType(t reflect.Type, size int, key bool) (string, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return c.boolean, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return c.smallInt, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return c.bigInt, nil
+	case reflect.Float32:
+		return c.float32, nil
+	case reflect.Float64:
+		return c.float64, nil
+	case reflect.String:
+		if size == 0 && key {
+			size = defaultKeySize
+		}
+		if size > 0 {
+			return fmt.Sprintf(c.varchar, size), nil
+		}
+		return c.text, nil
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return c.time, nil
+		}
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return "", fmt.Errorf("unsupported field type %s", t)
+	}
+	if key {
+		return "", errors.New("JSON column can't be a key")
+	}
+	return c.json, nil
+}
+
+var sqliteColumnTypes = columnTypes{
+	boolean:  "BOOLEAN",
+	smallInt: "INTEGER",
+	bigInt:   "INTEGER",
+	float32:  "REAL",
+	float64:  "REAL",
+	text:     "TEXT",
+	varchar:  "VARCHAR(%d)",
+	time:     "DATETIME",
+	json:     "TEXT",
+}
+
+func (d sqliteDialect) ColumnType(t reflect.Type, size int, key bool) (string, error) {
+	return sqliteColumnTypes.columnType(t, size, key)
+}
+
+// IdentityType returns INTEGER, an INTEGER primary key is an alias of the rowid.
+func (d sqliteDialect) IdentityType() string {
+	return "INTEGER"
+}
+
+var mysqlColumnTypes = columnTypes{
+	boolean:  "BOOLEAN",
+	smallInt: "INT",
+	bigInt:   "BIGINT",
+	float32:  "FLOAT",
+	float64:  "DOUBLE",
+	text:     "TEXT",
+	varchar:  "VARCHAR(%d)",
+	time:     "DATETIME(6)",
+	json:     "JSON",
+}
+
+func (d mysqlDialect) ColumnType(t reflect.Type, size int, key bool) (string, error) {
+	return mysqlColumnTypes.columnType(t, size, key)
+}
+
+func (d mysqlDialect) IdentityType() string {
+	return "BIGINT AUTO_INCREMENT"
+}
+
+var postgresColumnTypes = columnTypes{
+	boolean:  "BOOLEAN",
+	smallInt: "INTEGER",
+	bigInt:   "BIGINT",
+	float32:  "REAL",
+	float64:  "DOUBLE PRECISION",
+	text:     "TEXT",
+	varchar:  "VARCHAR(%d)",
+	time:     "TIMESTAMP WITH TIME ZONE",
+	json:     "JSONB",
+}
+
+func (d postgresDialect) ColumnType(t reflect.Type, size int, key bool) (string, error) {
+	return postgresColumnTypes.columnType(t, size, key)
+}
+
+func (d postgresDialect) IdentityType() string {
+	return "BIGINT GENERATED BY DEFAULT AS IDENTITY"
+}
+
+var sqlServerColumnTypes = columnTypes{
+	boolean:  "BIT",
+	smallInt: "INT",
+	bigInt:   "BIGINT",
+	float32:  "REAL",
+	float64:  "FLOAT",
+	text:     "NVARCHAR(MAX)",
+	varchar:  "NVARCHAR(%d)",
+	time:     "DATETIME2",
+	json:     "NVARCHAR(MAX)",
+}
+
+func (d sqlServerDialect) ColumnType(t reflect.Type, size int, key bool) (string, error) {
+	return sqlServerColumnTypes.columnType(t, size, key)
+}
+
+// IdentityType returns an IDENTITY column, which doesn't accept explicit values.
+func (d sqlServerDialect) IdentityType() string {
+	return "BIGINT IDENTITY(1,1)"
+}
+
+var oracleColumnTypes = columnTypes{
+	boolean:  "NUMBER(1)",
+	smallInt: "NUMBER(10)",
+	bigInt:   "NUMBER(19)",
+	float32:  "BINARY_FLOAT",
+	float64:  "BINARY_DOUBLE",
+	text:     "CLOB",
+	varchar:  "VARCHAR2(%d)",
+	time:     "TIMESTAMP",
+	json:     "CLOB",
+}
+
+func (d oracleDialect) ColumnType(t reflect.Type, size int, key bool) (string, error) {
+	return oracleColumnTypes.columnType(t, size, key)
+}
+
+func (d oracleDialect) IdentityType() string {
+	return "NUMBER(19) GENERATED BY DEFAULT AS IDENTITY"
+}
@@ -0,0 +1,93 @@
+package gosqlcrud
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+type ddlAddress struct {
+	City string `json:"city"`
+}
+
+type ddlUser struct {
+	Id        int64       `db:"ID" pk:"true"`
+	Email     string      `db:"EMAIL" unique:"true"`
+	Name      string      `db:"NAME" size:"100" index:"idx_user_name"`
+	Nickname  *string     `db:"NICKNAME" index:"idx_user_name"`
+	Score     float64     `db:"SCORE" default:"0"`
+	Active    bool        `db:"ACTIVE"`
+	BirthDate *time.Time  `db:"BIRTH_DATE"`
+	Address   *ddlAddress `db:"ADDRESS"`
+	Ignored   string
+}
+
+type ddlMembership struct {
+	UserId  int64  `db:"USER_ID" pk:"true"`
+	GroupId string `db:"GROUP_ID" pk:"true"`
+	Role    *int32 `db:"ROLE"`
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	statements, err := CreateTableSQL[ddlUser](SQLite, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE users (ID INTEGER NOT NULL, EMAIL VARCHAR(255) NOT NULL, NAME VARCHAR(100) NOT NULL, NICKNAME VARCHAR(255), SCORE REAL DEFAULT 0 NOT NULL, ACTIVE BOOLEAN NOT NULL, BIRTH_DATE DATETIME, ADDRESS TEXT, PRIMARY KEY (ID))",
+		"CREATE UNIQUE INDEX uq_users_EMAIL ON users (EMAIL)",
+		"CREATE INDEX idx_user_name ON users (NAME, NICKNAME)",
+	}, statements)
+
+	statements, err = CreateTableSQL[ddlUser](PostgreSQL, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (ID BIGINT GENERATED BY DEFAULT AS IDENTITY NOT NULL, EMAIL VARCHAR(255) NOT NULL, NAME VARCHAR(100) NOT NULL, NICKNAME VARCHAR(255), SCORE DOUBLE PRECISION DEFAULT 0 NOT NULL, ACTIVE BOOLEAN NOT NULL, BIRTH_DATE TIMESTAMP WITH TIME ZONE, ADDRESS JSONB, PRIMARY KEY (ID))", statements[0])
+
+	statements, err = CreateTableSQL[ddlMembership](SQLServer, "memberships")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CREATE TABLE memberships (USER_ID BIGINT NOT NULL, GROUP_ID NVARCHAR(255) NOT NULL, ROLE INT, PRIMARY KEY (USER_ID, GROUP_ID))"}, statements)
+
+	statements, err = CreateTableSQL[ddlMembership](Oracle, "memberships")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CREATE TABLE memberships (USER_ID NUMBER(19) NOT NULL, GROUP_ID VARCHAR2(255) NOT NULL, ROLE NUMBER(10), PRIMARY KEY (USER_ID, GROUP_ID))"}, statements)
+
+	_, err = CreateTableSQL[ddlUser](Unknown, "users")
+	assert.EqualError(t, err, "unsupported database type")
+	_, err = CreateTableSQL[struct {
+		Tags []string `db:"TAGS" index:"true"`
+	}](MySQL, "tags")
+	assert.EqualError(t, err, "TAGS: JSON column can't be a key")
+}
+
+func TestCreateTable(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	err = CreateTable[ddlUser](db, "users")
+	assert.NoError(t, err)
+
+	table, err := GetTable(db, "users")
+	assert.NoError(t, err)
+	nullable := map[string]bool{}
+	for _, column := range table.Columns {
+		nullable[column.Name] = column.Nullable
+	}
+	assert.Equal(t, map[string]bool{"ID": false, "EMAIL": false, "NAME": false, "NICKNAME": true, "SCORE": false, "ACTIVE": false, "BIRTH_DATE": true, "ADDRESS": true}, nullable)
+	assert.Len(t, table.Indexes, 2)
+
+	user := ddlUser{Email: "a@example.com", Name: "A", Address: &ddlAddress{City: "Paris"}}
+	_, err = Create(db, &user, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), user.Id)
+	_, err = Create(db, &ddlUser{Email: "a@example.com", Name: "B"}, "users")
+	assert.Error(t, err)
+
+	var users []ddlUser
+	err = QueryToStructs(db, &users, "SELECT * FROM users")
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "Paris", users[0].Address.City)
+	assert.Nil(t, users[0].BirthDate)
+}
@@ -0,0 +1,452 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Dialect describes the SQL flavor of a database. The five supported databases have built-in
+// dialects, others can be added with RegisterDialect.
+type Dialect interface {
+	// Name returns a short name of the dialect, e.g. "postgres".
+	Name() string
+	// Placeholder returns the bind parameter placeholder for the 0-based index.
+	Placeholder(index int) string
+	// ConvertValue converts a raw value scanned from a column of database type colType into
+	// its Go representation, or returns why it can't. raw is never nil.
+	ConvertValue(raw any, colType string) (any, error)
+	// UpsertSql returns the insert-or-update statement for table. The pkKeys are the conflict
+	// target, the nonPkKeys are updated when the row exists. The placeholders bind the values
+	// of pkKeys followed by the values of nonPkKeys.
+	UpsertSql(table string, pkKeys []string, nonPkKeys []string) (string, error)
+	// InsertReturningSql returns an INSERT statement for table that reports the value of the
+	// generated key column, and how the value is reported. columns and placeholders are
+	// comma separated lists, nextIndex is the index of the next free placeholder.
+	InsertReturningSql(table string, columns string, placeholders string, key string, nextIndex int) (string, ReturningMode)
+	// Probe reports whether conn is a database of this dialect, typically by querying its version.
+	Probe(ctx context.Context, conn DBContext) bool
+}
+
+// QuotingDialect is implemented by dialects whose identifiers aren't quoted with double quotes.
+type QuotingDialect interface {
+	Dialect
+	// QuoteIdentifier quotes a table or column name.
+	QuoteIdentifier(name string) string
+}
+
+// PaginationDialect is implemented by dialects that don't paginate with LIMIT and OFFSET.
+type PaginationDialect interface {
+	Dialect
+	// Paginate wraps sqlStatement to return at most limit rows, skipping offset rows.
+	Paginate(sqlStatement string, limit int, offset int) string
+}
+
+// QuoteIdentifier - quote a table or column name for dbType, with double quotes unless its
+// dialect implements QuotingDialect.
+func QuoteIdentifier(dbType DbType, name string) string {
+	if d, ok := dbType.Dialect().(QuotingDialect); ok {
+		return d.QuoteIdentifier(name)
+	}
+	return quoteWith(name, `"`, `"`)
+}
+
+// Paginate - wrap sqlStatement to return at most limit rows, skipping offset rows, with LIMIT
+// and OFFSET unless the dialect of dbType implements PaginationDialect. SQL Server and Oracle
+// require sqlStatement to have an ORDER BY.
+func Paginate(dbType DbType, sqlStatement string, limit int, offset int) string {
+	if d, ok := dbType.Dialect().(PaginationDialect); ok {
+		return d.Paginate(sqlStatement, limit, offset)
+	}
+	return limitOffset(sqlStatement, limit, offset)
+}
+
+// ReturningMode tells how an INSERT statement reports a generated key.
+type ReturningMode int
+
+const (
+	// ReturningRow - the statement returns the key as a single row with a single column.
+	ReturningRow ReturningMode = iota
+	// ReturningOutParam - the key is bound to a sql.Out parameter at the next free placeholder.
+	ReturningOutParam
+	// ReturningLastInsertId - the key is read from sql.Result.LastInsertId.
+	ReturningLastInsertId
+)
+
+type DbType int
+
+const (
+	Unknown DbType = iota
+	SQLite
+	MySQL
+	PostgreSQL
+	SQLServer
+	Oracle
+)
+
+// dialects is indexed by DbType, there is no dialect for Unknown.
+var dialects = []Dialect{nil, sqliteDialect{}, mysqlDialect{}, postgresDialect{}, sqlServerDialect{}, oracleDialect{}}
+var dialectsMutex = sync.RWMutex{}
+
+// RegisterDialect - add a dialect and return the DbType identifying it. Registered dialects
+// are probed before the built-in ones, the latest registered first. Probing only happens for
+// connections whose dialect is neither bound nor known from the driver, see RegisterDriver
+// and WithDialect.
+func RegisterDialect(dialect Dialect) DbType {
+	dialectsMutex.Lock()
+	defer dialectsMutex.Unlock()
+	dialects = append(dialects, dialect)
+	return DbType(len(dialects) - 1)
+}
+
+// Dialect returns the dialect of dbType, nil for Unknown.
+func (dbType DbType) Dialect() Dialect {
+	dialectsMutex.RLock()
+	defer dialectsMutex.RUnlock()
+	if dbType <= Unknown || int(dbType) >= len(dialects) {
+		return nil
+	}
+	return dialects[dbType]
+}
+
+func (dbType DbType) String() string {
+	if dialect := dbType.Dialect(); dialect != nil {
+		return dialect.Name()
+	}
+	return "unknown"
+}
+
+// driverDbTypes maps driver names and driver package paths to database types.
+var driverDbTypes = map[string]DbType{
+	"sqlite":                           SQLite,
+	"sqlite3":                          SQLite,
+	"modernc.org/sqlite":               SQLite,
+	"github.com/mattn/go-sqlite3":      SQLite,
+	"github.com/ncruces/go-sqlite3":    SQLite,
+	"mysql":                            MySQL,
+	"github.com/go-sql-driver/mysql":   MySQL,
+	"postgres":                         PostgreSQL,
+	"pgx":                              PostgreSQL,
+	"github.com/lib/pq":                PostgreSQL,
+	"github.com/jackc/pgx":             PostgreSQL,
+	"sqlserver":                        SQLServer,
+	"mssql":                            SQLServer,
+	"azuresql":                         SQLServer,
+	"github.com/microsoft/go-mssqldb":  SQLServer,
+	"github.com/denisenkom/go-mssqldb": SQLServer,
+	"oracle":                           Oracle,
+	"godror":                           Oracle,
+	"github.com/sijms/go-ora":          Oracle,
+	"github.com/godror/godror":         Oracle,
+}
+
+// RegisterDriver - associate a driver with dbType. name is either the driver name passed to
+// sql.Open, or the import path of the package defining the driver type. Connections opened
+// with this driver then use dbType without probing.
+func RegisterDriver(name string, dbType DbType) {
+	dialectsMutex.Lock()
+	defer dialectsMutex.Unlock()
+	driverDbTypes[name] = dbType
+}
+
+// Open - same as sql.Open, and binds the returned *sql.DB to the dialect of driverName.
+func Open(driverName string, dataSourceName string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	dialectsMutex.RLock()
+	dbType := driverDbTypes[driverName]
+	dialectsMutex.RUnlock()
+	if dbType == Unknown {
+		dbType = dbTypeFromDriver(db.Driver())
+	}
+	if dbType != Unknown {
+		cacheDbType(db, dbType)
+	}
+	return db, nil
+}
+
+// WithDialect - bind conn to dbType, so its dialect is never probed. It returns conn.
+// The binding of a *sql.DB, *sql.Tx or *sql.Conn goes away with the connection, any other
+// connection type is held until UnbindDialect is called.
+func WithDialect[T DB](conn T, dbType DbType) T {
+	bindDbType(conn, dbType)
+	return conn
+}
+
+// UnbindDialect - remove the dialect bound to conn by WithDialect.
+func UnbindDialect[T DB](conn T) {
+	uncacheDbType(conn)
+}
+
+// dbTypeFromDriver looks up the package path of the type of driver, or of any of its parent
+// paths, e.g. github.com/jackc/pgx for github.com/jackc/pgx/v5/stdlib.
+func dbTypeFromDriver(driver any) DbType {
+	t := reflect.TypeOf(driver)
+	if t == nil {
+		return Unknown
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	dialectsMutex.RLock()
+	defer dialectsMutex.RUnlock()
+	for pkgPath := t.PkgPath(); pkgPath != "" && pkgPath != "."; pkgPath = path.Dir(pkgPath) {
+		if dbType, ok := driverDbTypes[pkgPath]; ok {
+			return dbType
+		}
+	}
+	return Unknown
+}
+
+// dbTypeFromConn infers the database type from the driver of a *sql.DB or *sql.Conn.
+func dbTypeFromConn(ctx context.Context, conn DBContext) DbType {
+	switch c := unwrapConn(conn).(type) {
+	case *sql.DB:
+		return dbTypeFromDriver(c.Driver())
+	case *sql.Conn:
+		dbType := Unknown
+		c.Raw(func(driverConn any) error {
+			dbType = dbTypeFromDriver(driverConn)
+			return nil
+		})
+		return dbType
+	}
+	return Unknown
+}
+
+func probeDbType(ctx context.Context, conn DBContext) DbType {
+	dialectsMutex.RLock()
+	registered := slices.Clone(dialects)
+	dialectsMutex.RUnlock()
+
+	for i := len(registered) - 1; i > int(Oracle); i-- {
+		if registered[i].Probe(ctx, conn) {
+			return DbType(i)
+		}
+	}
+	// postgres goes first, a failed probe would abort its transaction. It shares the version
+	// query with mysql, which runs once for both.
+	if v, ok := probeVersion(ctx, conn, versionQuery, ""); ok {
+		return versionDbType(v)
+	}
+	for _, dbType := range []DbType{SQLServer, Oracle, SQLite} {
+		if registered[dbType].Probe(ctx, conn) {
+			return dbType
+		}
+	}
+	return Unknown
+}
+
+// versionQuery returns the version of postgres and mysql.
+const versionQuery = "SELECT VERSION() AS version"
+
+// versionDbType tells postgres from mysql by the result of versionQuery.
+func versionDbType(version string) DbType {
+	if strings.Contains(strings.ToLower(version), "postgres") {
+		return PostgreSQL
+	}
+	return MySQL
+}
+
+// probeVersion runs a query returning a version string, and reports whether it succeeded and
+// the version contains contains, case insensitively.
+func probeVersion(ctx context.Context, conn DBContext, query string, contains string) (string, bool) {
+	var v string
+	if err := conn.QueryRowContext(ctx, query).Scan(&v); err != nil {
+		return "", false
+	}
+	return v, strings.Contains(strings.ToLower(v), contains)
+}
+
+// joinKeys formats each key with format, where every %s stands for the key, and joins them with sep.
+func joinKeys(format string, keys []string, sep string) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = strings.ReplaceAll(format, "%s", k)
+	}
+	joined := strings.Join(parts, sep)
+	SqlSafe(&joined)
+	return joined
+}
+
+func placeholders(dialect Dialect, start int, n int) string {
+	qms := make([]string, n)
+	for i := range qms {
+		qms[i] = dialect.Placeholder(start + i)
+	}
+	return strings.Join(qms, ",")
+}
+
+// upsertOnConflict is the INSERT ... ON CONFLICT form of sqlite and postgres.
+func upsertOnConflict(dialect Dialect, table string, pkKeys []string, nonPkKeys []string) string {
+	keys := append(slices.Clone(pkKeys), nonPkKeys...)
+	insert := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s)`,
+		table, joinKeys("%s", keys, ","), placeholders(dialect, 0, len(keys)), joinKeys("%s", pkKeys, ","))
+	if len(nonPkKeys) == 0 {
+		return insert + " DO NOTHING"
+	}
+	return insert + " DO UPDATE SET " + joinKeys("%s=excluded.%s", nonPkKeys, ",")
+}
+
+// upsertMerge is the MERGE form of sql server and oracle. source is the USING clause with %s
+// standing for the selected placeholders.
+func upsertMerge(dialect Dialect, into string, using string, table string, pkKeys []string, nonPkKeys []string) string {
+	keys := append(slices.Clone(pkKeys), nonPkKeys...)
+	sources := make([]string, len(keys))
+	for i, k := range keys {
+		sources[i] = dialect.Placeholder(i) + " AS " + k
+	}
+	source := strings.Join(sources, ",")
+	SqlSafe(&source)
+	sqlStatement := fmt.Sprintf(into+" "+using+" ON (%s)", table, source, joinKeys("target.%s=source.%s", pkKeys, " AND "))
+	if len(nonPkKeys) > 0 {
+		sqlStatement += " WHEN MATCHED THEN UPDATE SET " + joinKeys("target.%s=source.%s", nonPkKeys, ",")
+	}
+	sqlStatement += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", joinKeys("%s", keys, ","), joinKeys("source.%s", keys, ","))
+	return sqlStatement
+}
+
+func limitOffset(sqlStatement string, limit int, offset int) string {
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", sqlStatement, limit, offset)
+}
+
+// offsetFetch is the standard pagination, sql server requires an ORDER BY for it.
+func offsetFetch(sqlStatement string, limit int, offset int) string {
+	return fmt.Sprintf("%s OFFSET %d ROWS FETCH NEXT %d ROWS ONLY", sqlStatement, offset, limit)
+}
+
+func quoteWith(name string, open string, close string) string {
+	return open + strings.ReplaceAll(name, close, close+close) + close
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string                 { return "sqlite" }
+func (sqliteDialect) Placeholder(index int) string { return "?" }
+func (sqliteDialect) ConvertValue(raw any, colType string) (any, error) {
+	result, err := convertBytes(raw, colType)
+	if err != nil {
+		return nil, err
+	}
+	// in sqlite, json columns fall here, if columnName contains "json" case insensitively
+	if v, ok := raw.(string); ok {
+		if colType == "" {
+			_v := strings.TrimSpace(v)
+			if strings.HasPrefix(_v, "{") && strings.HasSuffix(_v, "}") || strings.HasPrefix(_v, "[") && strings.HasSuffix(_v, "]") {
+				var a any
+				err := json.Unmarshal([]byte(_v), &a)
+				if err == nil {
+					result = &a
+				}
+			}
+		}
+	}
+	return result, nil
+}
+func (d sqliteDialect) UpsertSql(table string, pkKeys []string, nonPkKeys []string) (string, error) {
+	return upsertOnConflict(d, table, pkKeys, nonPkKeys), nil
+}
+func (sqliteDialect) InsertReturningSql(table string, columns string, placeholders string, key string, nextIndex int) (string, ReturningMode) {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) RETURNING %s`, table, columns, placeholders, key), ReturningRow
+}
+func (sqliteDialect) Probe(ctx context.Context, conn DBContext) bool {
+	_, ok := probeVersion(ctx, conn, "SELECT sqlite_version()", "")
+	return ok
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string                       { return "mysql" }
+func (mysqlDialect) Placeholder(index int) string       { return "?" }
+func (mysqlDialect) QuoteIdentifier(name string) string { return quoteWith(name, "`", "`") }
+func (mysqlDialect) ConvertValue(raw any, colType string) (any, error) {
+	return convertBytes(raw, colType)
+}
+func (d mysqlDialect) UpsertSql(table string, pkKeys []string, nonPkKeys []string) (string, error) {
+	keys := append(slices.Clone(pkKeys), nonPkKeys...)
+	updateKeys := nonPkKeys
+	if len(updateKeys) == 0 {
+		// a no-op assignment, mysql has no DO NOTHING
+		updateKeys = pkKeys[:1]
+	}
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s`,
+		table, joinKeys("%s", keys, ","), placeholders(d, 0, len(keys)), joinKeys("%s=VALUES(%s)", updateKeys, ",")), nil
+}
+func (mysqlDialect) InsertReturningSql(table string, columns string, placeholders string, key string, nextIndex int) (string, ReturningMode) {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, columns, placeholders), ReturningLastInsertId
+}
+func (mysqlDialect) Probe(ctx context.Context, conn DBContext) bool {
+	v, ok := probeVersion(ctx, conn, versionQuery, "")
+	return ok && versionDbType(v) == MySQL
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string                 { return "postgres" }
+func (postgresDialect) Placeholder(index int) string { return fmt.Sprintf("$%d", index+1) }
+func (postgresDialect) ConvertValue(raw any, colType string) (any, error) {
+	return convertBytes(raw, colType)
+}
+func (d postgresDialect) UpsertSql(table string, pkKeys []string, nonPkKeys []string) (string, error) {
+	return upsertOnConflict(d, table, pkKeys, nonPkKeys), nil
+}
+func (postgresDialect) InsertReturningSql(table string, columns string, placeholders string, key string, nextIndex int) (string, ReturningMode) {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) RETURNING %s`, table, columns, placeholders, key), ReturningRow
+}
+func (postgresDialect) Probe(ctx context.Context, conn DBContext) bool {
+	v, ok := probeVersion(ctx, conn, versionQuery, "")
+	return ok && versionDbType(v) == PostgreSQL
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Name() string                       { return "sqlserver" }
+func (sqlServerDialect) Placeholder(index int) string       { return fmt.Sprintf("@p%d", index+1) }
+func (sqlServerDialect) QuoteIdentifier(name string) string { return quoteWith(name, "[", "]") }
+func (sqlServerDialect) ConvertValue(raw any, colType string) (any, error) {
+	return convertBytes(raw, colType)
+}
+func (d sqlServerDialect) UpsertSql(table string, pkKeys []string, nonPkKeys []string) (string, error) {
+	// sql server requires MERGE to be terminated
+	return upsertMerge(d, "MERGE INTO %s AS target", "USING (SELECT %s) AS source", table, pkKeys, nonPkKeys) + ";", nil
+}
+func (sqlServerDialect) InsertReturningSql(table string, columns string, placeholders string, key string, nextIndex int) (string, ReturningMode) {
+	return fmt.Sprintf(`INSERT INTO %s (%s) OUTPUT INSERTED.%s VALUES (%s)`, table, columns, key, placeholders), ReturningRow
+}
+func (sqlServerDialect) Paginate(sqlStatement string, limit int, offset int) string {
+	return offsetFetch(sqlStatement, limit, offset)
+}
+func (sqlServerDialect) Probe(ctx context.Context, conn DBContext) bool {
+	_, ok := probeVersion(ctx, conn, "SELECT @@VERSION AS version", "microsoft")
+	return ok
+}
+
+type oracleDialect struct{}
+
+func (oracleDialect) Name() string                 { return "oracle" }
+func (oracleDialect) Placeholder(index int) string { return fmt.Sprintf(":%d", index+1) }
+func (oracleDialect) ConvertValue(raw any, colType string) (any, error) {
+	return convertStrings(raw, colType)
+}
+func (d oracleDialect) UpsertSql(table string, pkKeys []string, nonPkKeys []string) (string, error) {
+	// oracle doesn't accept AS before a table alias
+	return upsertMerge(d, "MERGE INTO %s target", "USING (SELECT %s FROM DUAL) source", table, pkKeys, nonPkKeys), nil
+}
+func (d oracleDialect) InsertReturningSql(table string, columns string, placeholders string, key string, nextIndex int) (string, ReturningMode) {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) RETURNING %s INTO %s`, table, columns, placeholders, key, d.Placeholder(nextIndex)), ReturningOutParam
+}
+func (oracleDialect) Paginate(sqlStatement string, limit int, offset int) string {
+	return offsetFetch(sqlStatement, limit, offset)
+}
+func (oracleDialect) Probe(ctx context.Context, conn DBContext) bool {
+	_, ok := probeVersion(ctx, conn, "SELECT BANNER FROM v$version", "oracle")
+	return ok
+}
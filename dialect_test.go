@@ -0,0 +1,188 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// markerDialect is sqlite, detected by the presence of a marker table.
+type markerDialect struct {
+	sqliteDialect
+}
+
+func (markerDialect) Name() string { return "marker" }
+func (markerDialect) Probe(ctx context.Context, conn DBContext) bool {
+	_, ok := probeVersion(ctx, conn, "SELECT name FROM dialect_marker", "marker")
+	return ok
+}
+
+func TestRegisterDialect(t *testing.T) {
+	markerType := RegisterDialect(markerDialect{})
+	assert.Equal(t, "marker", markerType.String())
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = Exec(db, "CREATE TABLE dialect_marker (name TEXT)")
+	assert.NoError(t, err)
+	_, err = Exec(db, "INSERT INTO dialect_marker VALUES ('marker')")
+	assert.NoError(t, err)
+
+	// the driver of a *sql.DB tells its dialect, a *sql.Tx is probed
+	assert.Equal(t, SQLite, GetDbType(db))
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	defer tx.Rollback()
+	assert.Equal(t, markerType, GetDbType(tx))
+
+	other, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "other.db"))
+	assert.NoError(t, err)
+	defer other.Close()
+	otherTx, err := other.Begin()
+	assert.NoError(t, err)
+	defer otherTx.Rollback()
+	assert.Equal(t, SQLite, GetDbType(otherTx))
+
+	_, err = Exec(tx, "CREATE TABLE test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+	name := "Alpha"
+	_, err = Create(tx, &Test{Id: 1, Name: &name}, "test")
+	assert.NoError(t, err)
+	resultStruct := Test{Id: 1}
+	assert.NoError(t, Retrieve(tx, &resultStruct, "test"))
+	assert.Equal(t, "Alpha", *resultStruct.Name)
+}
+
+func TestBindDialect(t *testing.T) {
+	db, err := Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, SQLite, GetDbType(db))
+
+	// a bound dialect wins over the driver, nothing is probed
+	bound, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	defer bound.Close()
+	assert.Equal(t, bound, WithDialect(bound, PostgreSQL))
+	assert.Equal(t, PostgreSQL, GetDbType(bound))
+
+	assert.Equal(t, SQLite, dbTypeFromDriver(db.Driver()))
+	assert.Equal(t, Unknown, dbTypeFromDriver(nil))
+}
+
+func TestBuiltinDialects(t *testing.T) {
+	assert.Equal(t, "postgres", PostgreSQL.String())
+	assert.Equal(t, "unknown", Unknown.String())
+	assert.Nil(t, Unknown.Dialect())
+
+	assert.Equal(t, `"a""b"`, QuoteIdentifier(SQLite, `a"b`))
+	assert.Equal(t, "`a``b`", QuoteIdentifier(MySQL, "a`b"))
+	assert.Equal(t, "[a]]b]", QuoteIdentifier(SQLServer, "a]b"))
+
+	assert.Equal(t, "SELECT * FROM t LIMIT 10 OFFSET 20", Paginate(PostgreSQL, "SELECT * FROM t", 10, 20))
+	assert.Equal(t, "SELECT * FROM t ORDER BY id OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY", Paginate(SQLServer, "SELECT * FROM t ORDER BY id", 10, 20))
+
+	sqlStatement, mode := Oracle.Dialect().InsertReturningSql("t", "NAME", ":1", "ID", 1)
+	assert.Equal(t, "INSERT INTO t (NAME) VALUES (:1) RETURNING ID INTO :2", sqlStatement)
+	assert.Equal(t, ReturningOutParam, mode)
+	sqlStatement, mode = SQLServer.Dialect().InsertReturningSql("t", "NAME", "@p1", "ID", 1)
+	assert.Equal(t, "INSERT INTO t (NAME) OUTPUT INSERTED.ID VALUES (@p1)", sqlStatement)
+	assert.Equal(t, ReturningRow, mode)
+
+	value, err := MySQL.Dialect().ConvertValue([]byte("7"), "INT")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, value)
+	_, err = MySQL.Dialect().ConvertValue([]byte("seven"), "INT")
+	assert.Error(t, err)
+}
+
+// countingDB is a connection type of another library, counting the probe queries run on it.
+type countingDB struct {
+	*sql.DB
+	queryRows []string
+}
+
+func (c *countingDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	c.queryRows = append(c.queryRows, query)
+	return c.DB.QueryRowContext(ctx, query, args...)
+}
+
+func TestDbTypeCacheOtherConn(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	_, err = Exec(db, "CREATE TABLE probe_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+
+	type ProbeTest struct {
+		Id   int    `db:"ID" pk:"true"`
+		Name string `db:"NAME"`
+	}
+	conn := &countingDB{DB: db}
+	for i := 1; i <= 5; i++ {
+		_, err = Create(conn, &ProbeTest{Id: i, Name: "name"}, "probe_test")
+		assert.NoError(t, err)
+	}
+	probes := len(conn.queryRows)
+	assert.Greater(t, probes, 0)
+	assert.Equal(t, SQLite, GetDbType(conn))
+	assert.Len(t, conn.queryRows, probes)
+}
+
+func TestProbeDbType(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// every version query of the builtin dialects runs once, after those of registered dialects
+	conn := &countingDB{DB: db}
+	assert.Equal(t, SQLite, probeDbType(context.Background(), conn))
+	assert.GreaterOrEqual(t, len(conn.queryRows), 4)
+	assert.Equal(t, []string{versionQuery, "SELECT @@VERSION AS version", "SELECT BANNER FROM v$version", "SELECT sqlite_version()"}, conn.queryRows[len(conn.queryRows)-4:])
+
+	assert.Equal(t, PostgreSQL, versionDbType("PostgreSQL 17.2 on x86_64-pc-linux-gnu"))
+	assert.Equal(t, MySQL, versionDbType("8.4.3"))
+	assert.Equal(t, MySQL, versionDbType("11.4.4-MariaDB"))
+}
+
+func TestDbTypeCache(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	tx, err := Begin(WithDialect(db, MySQL))
+	assert.NoError(t, err)
+	// inherited from db, a probe would find sqlite
+	assert.Equal(t, MySQL, GetDbType(tx))
+	assert.NoError(t, tx.Rollback())
+
+	countEntries := func() int {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		return len(dbTypes)
+	}
+	before := countEntries()
+	for range 100 {
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		assert.Equal(t, SQLite, GetDbType(tx))
+		assert.NoError(t, tx.Rollback())
+	}
+	assert.Equal(t, before+100, countEntries())
+	for range 10 {
+		runtime.GC()
+		if countEntries() <= before {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, countEntries(), before)
+}
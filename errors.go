@@ -0,0 +1,460 @@
+package gosqlcrud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned by Retrieve when no row has the primary key of the struct.
+var ErrNotFound = errors.New("no record found")
+
+// ErrStaleObject is returned by Update and Delete when the version field of a struct no longer
+// matches its row, because the row was changed or deleted by someone else since it was read.
+var ErrStaleObject = errors.New("stale object")
+
+// ConstraintKind is the kind of constraint a statement violated.
+type ConstraintKind int
+
+const (
+	// UniqueViolation - a unique index, unique constraint or primary key.
+	UniqueViolation ConstraintKind = iota + 1
+	// ForeignKeyViolation - a foreign key, either by a missing parent row or by remaining child rows.
+	ForeignKeyViolation
+	// NotNullViolation - a NOT NULL column set to NULL, or left out without default.
+	NotNullViolation
+	// CheckViolation - a CHECK constraint.
+	CheckViolation
+)
+
+func (k ConstraintKind) String() string {
+	switch k {
+	case UniqueViolation:
+		return "unique"
+	case ForeignKeyViolation:
+		return "foreign key"
+	case NotNullViolation:
+		return "not null"
+	case CheckViolation:
+		return "check"
+	}
+	return "unknown"
+}
+
+// ConstraintError is a constraint violation reported by the database. The functions of this
+// package return it in place of the driver error when the dialect of the connection
+// recognizes one, use errors.As to get it.
+type ConstraintError struct {
+	Kind ConstraintKind
+	// Constraint is the name of the constraint, or the column for databases that don't name
+	// it, e.g. the table.column list of a SQLite unique constraint. Empty if it isn't reported.
+	Constraint string
+	// Table is the table of the constraint as reported by the database, or the table passed to
+	// Create, Update, Delete or the other functions taking a table if it isn't reported.
+	Table string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ConstraintError) Error() string {
+	msg := e.Kind.String() + " constraint"
+	if e.Constraint != "" {
+		msg += " " + e.Constraint
+	}
+	msg += " violated"
+	if e.Table != "" {
+		msg += " on " + e.Table
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// ConstraintErrorDialect is implemented by dialects that can recognize constraint violations
+// in the errors of their drivers.
+type ConstraintErrorDialect interface {
+	Dialect
+	// ConstraintViolation returns the constraint violation err reports, nil if it isn't one.
+	ConstraintViolation(err error) *ConstraintError
+}
+
+// constraintError returns err as a *ConstraintError if the dialect of dbType recognizes it.
+func constraintError(dbType DbType, err error) error {
+	var ce *ConstraintError
+	if err == nil || errors.As(err, &ce) {
+		return err
+	}
+	d, ok := dbType.Dialect().(ConstraintErrorDialect)
+	if !ok {
+		return err
+	}
+	ce = d.ConstraintViolation(err)
+	if ce == nil {
+		return err
+	}
+	if ce.Err == nil {
+		ce.Err = err
+	}
+	return ce
+}
+
+// QueryError is returned when a statement run by a function of this package fails. It wraps
+// the driver error, or the *ConstraintError recognized in it, so errors.Is and errors.As see
+// through it.
+type QueryError struct {
+	// Op is the function that ran the statement, e.g. "Create" or "QueryToMaps".
+	Op string
+	// Table is the table passed to Op, empty for functions that take a SQL statement.
+	Table    string
+	Sql      string
+	DbType   DbType
+	ArgCount int
+	// Args are the bound arguments, nil if they are redacted, see SetRedactArgs and WithLogger.
+	Args []any
+	Err  error
+}
+
+func (e *QueryError) Error() string {
+	op := e.Op
+	if e.Table != "" {
+		op += " " + e.Table
+	}
+	return fmt.Sprintf("%s: %s: %v", op, e.Sql, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// queryError wraps err, the failure of sqlStatement run by op on conn, in a *QueryError.
+// Errors that already are one are returned as they are.
+func queryError(ctx context.Context, conn DBContext, op string, table string, sqlStatement string, args []any, err error) error {
+	var qe *QueryError
+	if err == nil || errors.As(err, &qe) {
+		return err
+	}
+	dbType := GetDbTypeContext(ctx, conn)
+	qe = &QueryError{
+		Op:       op,
+		Table:    table,
+		Sql:      sqlStatement,
+		DbType:   dbType,
+		ArgCount: len(args),
+		Err:      constraintError(dbType, err),
+	}
+	if _, redact := loggerFor(conn); !redact {
+		qe.Args = args
+	}
+	return qe
+}
+
+// queryTable sets the op and table of a *QueryError in err, returned by a statement that op
+// ran on table. A *ConstraintError in err gets the table if the database didn't report it.
+func queryTable(err error, op string, table string) error {
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		qe.Op = op
+		qe.Table = table
+	}
+	var ce *ConstraintError
+	if errors.As(err, &ce) && ce.Table == "" {
+		ce.Table = table
+	}
+	return err
+}
+
+// notFoundError returns the ErrNotFound of the row of table with the primary key pkMap.
+func notFoundError(table string, pkMap DbMap) error {
+	return fmt.Errorf("%w: %s, %v", ErrNotFound, table, pkMap)
+}
+
+// Drivers report errors with their own types. The helpers below read the native error code
+// without importing any driver, by the methods and fields the common drivers use.
+
+// errorSQLState returns the SQLSTATE of the first error in the chain of err that has one,
+// e.g. the Code of a pgx *pgconn.PgError or a lib/pq *pq.Error.
+func errorSQLState(err error) (string, bool) {
+	for _, e := range errorChain(err) {
+		if s, ok := e.(interface{ SQLState() string }); ok {
+			return s.SQLState(), true
+		}
+		if v, ok := errorField(e, "Code"); ok && v.Kind() == reflect.String {
+			return v.String(), true
+		}
+	}
+	return "", false
+}
+
+// errorNumber returns the numeric code of the first error in the chain of err that has one,
+// e.g. the Number of a *mysql.MySQLError or mssql.Error, or the Code of a SQLite error.
+func errorNumber(err error) (int, bool) {
+	for _, e := range errorChain(err) {
+		switch n := e.(type) {
+		case interface{ SQLErrorNumber() int32 }:
+			return int(n.SQLErrorNumber()), true
+		case interface{ Code() int }:
+			return n.Code(), true
+		}
+		for _, name := range []string{"Number", "Code", "ErrCode"} {
+			v, ok := errorField(e, name)
+			if !ok {
+				continue
+			}
+			switch {
+			case v.CanInt():
+				return int(v.Int()), true
+			case v.CanUint():
+				return int(v.Uint()), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// errorString returns the first non-empty string field of the names in the chain of err,
+// e.g. the ConstraintName of a pgx *pgconn.PgError or the Constraint of a lib/pq *pq.Error.
+func errorString(err error, names ...string) string {
+	for _, e := range errorChain(err) {
+		for _, name := range names {
+			if v, ok := errorField(e, name); ok && v.Kind() == reflect.String && v.String() != "" {
+				return v.String()
+			}
+		}
+	}
+	return ""
+}
+
+// errorChain returns err and the errors it wraps, depth first.
+func errorChain(err error) []error {
+	var chain []error
+	var walk func(error)
+	walk = func(err error) {
+		for err != nil {
+			chain = append(chain, err)
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, e := range joined.Unwrap() {
+					walk(e)
+				}
+				return
+			}
+			err = errors.Unwrap(err)
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// errorField returns the exported field name of the struct err, or err points to.
+func errorField(err error, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	f := v.FieldByName(name)
+	if !f.IsValid() || !f.CanInterface() {
+		return reflect.Value{}, false
+	}
+	return f, true
+}
+
+// submatch returns the first submatch of re in s, "" if re doesn't match.
+func submatch(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+var sqliteConstraintMessage = regexp.MustCompile(`(?m)(UNIQUE|NOT NULL|CHECK|FOREIGN KEY) constraint failed(?:: (.*?))?(?: \(\d+\))?$`)
+
+// ConstraintViolation recognizes SQLITE_CONSTRAINT and its extended codes. SQLite reports the
+// table.column list of unique and not null constraints, and no name of foreign keys.
+func (d sqliteDialect) ConstraintViolation(err error) *ConstraintError {
+	code, ok := 0, false
+	for _, e := range errorChain(err) {
+		// the extended code of mattn/go-sqlite3, the Code of modernc.org/sqlite is extended
+		if v, found := errorField(e, "ExtendedCode"); found && v.CanInt() {
+			code, ok = int(v.Int()), true
+			break
+		}
+	}
+	if !ok {
+		code, ok = errorNumber(err)
+	}
+	if !ok || code&0xff != 19 {
+		return nil
+	}
+	m := sqliteConstraintMessage.FindStringSubmatch(err.Error())
+	ce := &ConstraintError{}
+	switch {
+	case code == 2067 || code == 1555 || m != nil && m[1] == "UNIQUE":
+		ce.Kind = UniqueViolation
+	case code == 787 || m != nil && m[1] == "FOREIGN KEY":
+		ce.Kind = ForeignKeyViolation
+	case code == 1299 || m != nil && m[1] == "NOT NULL":
+		ce.Kind = NotNullViolation
+	case code == 275 || m != nil && m[1] == "CHECK":
+		ce.Kind = CheckViolation
+	default:
+		return nil
+	}
+	if m != nil {
+		ce.Constraint = m[2]
+		if ce.Kind == UniqueViolation || ce.Kind == NotNullViolation {
+			ce.Table, _, _ = strings.Cut(m[2], ".")
+		}
+	}
+	return ce
+}
+
+var (
+	mysqlDuplicateKey = regexp.MustCompile("for key '([^']*)'")
+	mysqlForeignKey   = regexp.MustCompile("`[^`]*`\\.`([^`]*)`, CONSTRAINT `([^`]*)`")
+	mysqlColumn       = regexp.MustCompile("(?:Column|Field) '([^']*)'")
+	mysqlCheck        = regexp.MustCompile("Check constraint '([^']*)'")
+)
+
+// ConstraintViolation recognizes duplicate keys (1062), foreign keys (1216, 1217, 1451, 1452),
+// null columns (1048, 1364) and check constraints (3819).
+func (d mysqlDialect) ConstraintViolation(err error) *ConstraintError {
+	code, ok := errorNumber(err)
+	if !ok {
+		return nil
+	}
+	msg := err.Error()
+	switch code {
+	case 1062:
+		// MySQL 8 reports the key as table.key
+		key := submatch(mysqlDuplicateKey, msg)
+		if table, name, found := strings.Cut(key, "."); found {
+			return &ConstraintError{Kind: UniqueViolation, Constraint: name, Table: table}
+		}
+		return &ConstraintError{Kind: UniqueViolation, Constraint: key}
+	case 1216, 1217, 1451, 1452:
+		ce := &ConstraintError{Kind: ForeignKeyViolation}
+		if m := mysqlForeignKey.FindStringSubmatch(msg); m != nil {
+			ce.Table, ce.Constraint = m[1], m[2]
+		}
+		return ce
+	case 1048, 1364:
+		return &ConstraintError{Kind: NotNullViolation, Constraint: submatch(mysqlColumn, msg)}
+	case 3819:
+		return &ConstraintError{Kind: CheckViolation, Constraint: submatch(mysqlCheck, msg)}
+	}
+	return nil
+}
+
+// ConstraintViolation recognizes the SQLSTATEs 23505, 23503, 23502 and 23514. The constraint
+// and table are read from the error fields of pgx and lib/pq.
+func (d postgresDialect) ConstraintViolation(err error) *ConstraintError {
+	state, ok := errorSQLState(err)
+	if !ok {
+		return nil
+	}
+	ce := &ConstraintError{
+		Constraint: errorString(err, "ConstraintName", "Constraint"),
+		Table:      errorString(err, "TableName", "Table"),
+	}
+	switch state {
+	case "23505":
+		ce.Kind = UniqueViolation
+	case "23503":
+		ce.Kind = ForeignKeyViolation
+	case "23502":
+		ce.Kind = NotNullViolation
+		if ce.Constraint == "" {
+			ce.Constraint = errorString(err, "ColumnName", "Column")
+		}
+	case "23514":
+		ce.Kind = CheckViolation
+	default:
+		return nil
+	}
+	return ce
+}
+
+var (
+	sqlServerConstraint  = regexp.MustCompile(`constraint ["']([^"']*)["']`)
+	sqlServerUniqueIndex = regexp.MustCompile(`unique index '([^']*)'`)
+	sqlServerObject      = regexp.MustCompile(`(?:object|table) ["']([^"']*)["']`)
+	sqlServerColumn      = regexp.MustCompile(`column '([^']*)'`)
+)
+
+// ConstraintViolation recognizes duplicate keys (2601, 2627), foreign key and check conflicts
+// (547) and null columns (515).
+func (d sqlServerDialect) ConstraintViolation(err error) *ConstraintError {
+	code, ok := errorNumber(err)
+	if !ok {
+		return nil
+	}
+	msg := err.Error()
+	ce := &ConstraintError{Table: submatch(sqlServerObject, msg)}
+	switch code {
+	case 2627:
+		ce.Kind = UniqueViolation
+		ce.Constraint = submatch(sqlServerConstraint, msg)
+	case 2601:
+		ce.Kind = UniqueViolation
+		ce.Constraint = submatch(sqlServerUniqueIndex, msg)
+	case 547:
+		ce.Kind = ForeignKeyViolation
+		if strings.Contains(msg, "CHECK constraint") {
+			ce.Kind = CheckViolation
+		}
+		ce.Constraint = submatch(sqlServerConstraint, msg)
+	case 515:
+		ce.Kind = NotNullViolation
+		ce.Constraint = submatch(sqlServerColumn, msg)
+	default:
+		return nil
+	}
+	return ce
+}
+
+var (
+	oracleCode       = regexp.MustCompile(`ORA-(\d{5})`)
+	oracleConstraint = regexp.MustCompile(`constraint \(([^)]*)\)`)
+	oracleColumn     = regexp.MustCompile(`\("[^"]*"\."([^"]*)"\."([^"]*)"\)`)
+)
+
+// ConstraintViolation recognizes ORA-00001, ORA-02291, ORA-02292, ORA-01400, ORA-01407 and
+// ORA-02290. The code is read from the error, or from its message for drivers without one.
+func (d oracleDialect) ConstraintViolation(err error) *ConstraintError {
+	msg := err.Error()
+	code, ok := errorNumber(err)
+	if !ok {
+		code, _ = strconv.Atoi(submatch(oracleCode, msg))
+	}
+	ce := &ConstraintError{Constraint: submatch(oracleConstraint, msg)}
+	switch code {
+	case 1:
+		ce.Kind = UniqueViolation
+	case 2291, 2292:
+		ce.Kind = ForeignKeyViolation
+	case 2290:
+		ce.Kind = CheckViolation
+	case 1400, 1407:
+		ce.Kind = NotNullViolation
+		if m := oracleColumn.FindStringSubmatch(msg); m != nil {
+			ce.Table, ce.Constraint = m[1], m[2]
+		}
+	default:
+		return nil
+	}
+	return ce
+}
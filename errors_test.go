@@ -0,0 +1,210 @@
+package gosqlcrud
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestConstraintError(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	for _, statement := range []string{
+		"PRAGMA foreign_keys = ON",
+		"CREATE TABLE parent (ID INTEGER PRIMARY KEY, NAME TEXT NOT NULL UNIQUE)",
+		"CREATE TABLE child (ID INTEGER PRIMARY KEY, PARENT_ID INTEGER REFERENCES parent(ID), AGE INTEGER CONSTRAINT age_check CHECK (AGE >= 0))",
+	} {
+		_, err = Exec(db, statement)
+		assert.NoError(t, err)
+	}
+
+	type Parent struct {
+		Id   int     `db:"ID" pk:"true"`
+		Name *string `db:"NAME"`
+	}
+	type Child struct {
+		Id       int `db:"ID" pk:"true"`
+		ParentId int `db:"PARENT_ID"`
+		Age      int `db:"AGE"`
+	}
+	name := "a"
+	_, err = Create(db, &Parent{Id: 1, Name: &name}, "parent")
+	assert.NoError(t, err)
+
+	var ce *ConstraintError
+	_, err = Create(db, &Parent{Id: 2, Name: &name}, "parent")
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, UniqueViolation, ce.Kind)
+	assert.Equal(t, "parent.NAME", ce.Constraint)
+	assert.Equal(t, "parent", ce.Table)
+	assert.Contains(t, err.Error(), "unique constraint parent.NAME violated on parent")
+
+	_, err = Exec(db, "INSERT INTO parent (ID, NAME) VALUES (1, 'b')")
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, UniqueViolation, ce.Kind)
+
+	_, err = Create(db, &Parent{Id: 2}, "parent")
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, NotNullViolation, ce.Kind)
+	assert.Equal(t, "parent.NAME", ce.Constraint)
+
+	_, err = Create(db, &Child{Id: 1, ParentId: 2}, "child")
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, ForeignKeyViolation, ce.Kind)
+	assert.Equal(t, "", ce.Constraint)
+	// SQLite doesn't report the table of foreign keys, it's the table passed to Create
+	assert.Equal(t, "child", ce.Table)
+
+	_, err = Create(db, &Child{Id: 1, ParentId: 1, Age: -1}, "child")
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, CheckViolation, ce.Kind)
+	assert.Equal(t, "age_check", ce.Constraint)
+
+	_, err = Create(db, &Child{Id: 1, ParentId: 1, Age: 1}, "child")
+	assert.NoError(t, err)
+	_, err = HardDelete(db, &Parent{Id: 1}, "parent")
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, ForeignKeyViolation, ce.Kind)
+	assert.Equal(t, "parent", ce.Table)
+
+	// other errors are returned as they are
+	_, err = Exec(db, "INSERT INTO no_such_table (ID) VALUES (1)")
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &ce))
+
+	err = Retrieve(db, &Parent{Id: 3}, "parent")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "parent")
+}
+
+func TestQueryError(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = Exec(db, "CREATE TABLE query_error (ID INTEGER PRIMARY KEY, NAME TEXT UNIQUE)")
+	assert.NoError(t, err)
+
+	type QueryErrorTest struct {
+		Id   int    `db:"ID" pk:"true"`
+		Name string `db:"NAME"`
+	}
+	_, err = Create(db, &QueryErrorTest{Id: 1, Name: "a"}, "query_error")
+	assert.NoError(t, err)
+
+	var qe *QueryError
+	_, err = Create(db, &QueryErrorTest{Id: 2, Name: "a"}, "query_error")
+	assert.ErrorAs(t, err, &qe)
+	assert.Equal(t, "Create", qe.Op)
+	assert.Equal(t, "query_error", qe.Table)
+	assert.Equal(t, "INSERT INTO query_error (ID,NAME) VALUES (?,?)", qe.Sql)
+	assert.Equal(t, SQLite, qe.DbType)
+	assert.Equal(t, 2, qe.ArgCount)
+	assert.Equal(t, []any{2, "a"}, qe.Args)
+	assert.True(t, strings.HasPrefix(err.Error(), "Create query_error: INSERT INTO query_error"))
+	// the constraint violation and the driver error are still found
+	var ce *ConstraintError
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, UniqueViolation, ce.Kind)
+	_, ok := errorNumber(err)
+	assert.True(t, ok)
+
+	_, err = Create(db, &QueryErrorTest{Id: 2, Name: "b"}, "query_error")
+	assert.NoError(t, err)
+	_, err = Update(db, &QueryErrorTest{Id: 2, Name: "a"}, "query_error")
+	assert.ErrorAs(t, err, &qe)
+	assert.Equal(t, "Update", qe.Op)
+
+	_, err = QueryToMaps(db, "SELECT * FROM no_such_table WHERE ID=?", 1)
+	assert.ErrorAs(t, err, &qe)
+	assert.Equal(t, "QueryToMaps", qe.Op)
+	assert.Equal(t, "", qe.Table)
+	assert.Equal(t, []any{1}, qe.Args)
+
+	// arguments are left out when they are redacted from the logs
+	SetRedactArgs(true)
+	_, err = Exec(db, "INSERT INTO query_error (ID, NAME) VALUES (?, ?)", 3, "a")
+	SetRedactArgs(false)
+	assert.ErrorAs(t, err, &qe)
+	assert.Equal(t, "Exec", qe.Op)
+	assert.Equal(t, 2, qe.ArgCount)
+	assert.Nil(t, qe.Args)
+	_, err = Exec(WithLogger(db, nil, true), "INSERT INTO query_error (ID, NAME) VALUES (?, ?)", 3, "a")
+	assert.ErrorAs(t, err, &qe)
+	assert.Nil(t, qe.Args)
+
+	// sentinel errors aren't query errors
+	err = Retrieve(db, &QueryErrorTest{Id: 3}, "query_error")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.False(t, errors.As(err, &qe))
+}
+
+type pgxError struct {
+	Code           string
+	ConstraintName string
+	TableName      string
+	ColumnName     string
+}
+
+func (e *pgxError) Error() string { return "pg " + e.Code }
+
+type mysqlMessageError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlMessageError) Error() string { return fmt.Sprintf("Error %d: %s", e.Number, e.Message) }
+
+type mssqlMessageError struct {
+	Number  int32
+	Message string
+}
+
+func (e mssqlMessageError) Error() string         { return "mssql: " + e.Message }
+func (e mssqlMessageError) SQLErrorNumber() int32 { return e.Number }
+
+func TestConstraintViolation(t *testing.T) {
+	tests := []struct {
+		dbType DbType
+		err    error
+		want   *ConstraintError
+	}{
+		{PostgreSQL, &pgxError{Code: "23505", ConstraintName: "users_email_key", TableName: "users"}, &ConstraintError{Kind: UniqueViolation, Constraint: "users_email_key", Table: "users"}},
+		{PostgreSQL, &pgxError{Code: "23503", ConstraintName: "orders_user_id_fkey", TableName: "orders"}, &ConstraintError{Kind: ForeignKeyViolation, Constraint: "orders_user_id_fkey", Table: "orders"}},
+		{PostgreSQL, &pgxError{Code: "23502", TableName: "users", ColumnName: "name"}, &ConstraintError{Kind: NotNullViolation, Constraint: "name", Table: "users"}},
+		{PostgreSQL, &pgxError{Code: "23514", ConstraintName: "age_check", TableName: "users"}, &ConstraintError{Kind: CheckViolation, Constraint: "age_check", Table: "users"}},
+		{PostgreSQL, &pgxError{Code: "40001"}, nil},
+		{MySQL, &mysqlMessageError{1062, "Duplicate entry 'a' for key 'users.email'"}, &ConstraintError{Kind: UniqueViolation, Constraint: "email", Table: "users"}},
+		{MySQL, &mysqlMessageError{1062, "Duplicate entry '1' for key 'PRIMARY'"}, &ConstraintError{Kind: UniqueViolation, Constraint: "PRIMARY"}},
+		{MySQL, &mysqlMessageError{1452, "Cannot add or update a child row: a foreign key constraint fails (`app`.`orders`, CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"}, &ConstraintError{Kind: ForeignKeyViolation, Constraint: "fk_user", Table: "orders"}},
+		{MySQL, &mysqlMessageError{1048, "Column 'name' cannot be null"}, &ConstraintError{Kind: NotNullViolation, Constraint: "name"}},
+		{MySQL, &mysqlMessageError{3819, "Check constraint 'age_check' is violated."}, &ConstraintError{Kind: CheckViolation, Constraint: "age_check"}},
+		{MySQL, &mysqlMessageError{1213, "Deadlock found"}, nil},
+		{SQLServer, mssqlMessageError{2627, "Violation of UNIQUE KEY constraint 'UQ_email'. Cannot insert duplicate key in object 'dbo.users'. The duplicate key value is (a)."}, &ConstraintError{Kind: UniqueViolation, Constraint: "UQ_email", Table: "dbo.users"}},
+		{SQLServer, mssqlMessageError{2601, "Cannot insert duplicate key row in object 'dbo.users' with unique index 'IX_email'. The duplicate key value is (a)."}, &ConstraintError{Kind: UniqueViolation, Constraint: "IX_email", Table: "dbo.users"}},
+		{SQLServer, mssqlMessageError{547, `The INSERT statement conflicted with the FOREIGN KEY constraint "FK_user". The conflict occurred in database "app", table "dbo.users", column 'id'.`}, &ConstraintError{Kind: ForeignKeyViolation, Constraint: "FK_user", Table: "dbo.users"}},
+		{SQLServer, mssqlMessageError{547, `The INSERT statement conflicted with the CHECK constraint "CK_age". The conflict occurred in database "app", table "dbo.users", column 'age'.`}, &ConstraintError{Kind: CheckViolation, Constraint: "CK_age", Table: "dbo.users"}},
+		{SQLServer, mssqlMessageError{515, "Cannot insert the value NULL into column 'name', table 'app.dbo.users'; column does not allow nulls. INSERT fails."}, &ConstraintError{Kind: NotNullViolation, Constraint: "name", Table: "app.dbo.users"}},
+		{Oracle, errors.New("ORA-00001: unique constraint (APP.UQ_EMAIL) violated"), &ConstraintError{Kind: UniqueViolation, Constraint: "APP.UQ_EMAIL"}},
+		{Oracle, errors.New("ORA-02291: integrity constraint (APP.FK_USER) violated - parent key not found"), &ConstraintError{Kind: ForeignKeyViolation, Constraint: "APP.FK_USER"}},
+		{Oracle, errors.New(`ORA-01400: cannot insert NULL into ("APP"."USERS"."NAME")`), &ConstraintError{Kind: NotNullViolation, Constraint: "NAME", Table: "USERS"}},
+		{Oracle, errors.New("ORA-02290: check constraint (APP.CK_AGE) violated"), &ConstraintError{Kind: CheckViolation, Constraint: "APP.CK_AGE"}},
+		{Oracle, errors.New("ORA-00942: table or view does not exist"), nil},
+	}
+	for _, test := range tests {
+		err := fmt.Errorf("wrapped: %w", test.err)
+		got := constraintError(test.dbType, err)
+		if test.want == nil {
+			assert.Equal(t, err, got)
+			continue
+		}
+		test.want.Err = err
+		assert.Equal(t, test.want, got, test.err.Error())
+		assert.ErrorIs(t, got, test.err)
+	}
+}
@@ -1,15 +1,15 @@
 package gosqlcrud
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -21,6 +21,39 @@ type DB interface {
 	QueryRow(query string, args ...any) *sql.Row
 }
 
+// DBContext is implemented by connections that accept a context, such as
+// *sql.DB, *sql.Tx and *sql.Conn.
+type DBContext interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// dbAdapter lets a plain DB be used where a DBContext is expected. The context is ignored.
+type dbAdapter struct {
+	DB
+}
+
+func (a dbAdapter) QueryContext(_ context.Context, query string, args ...any) (*sql.Rows, error) {
+	return a.Query(query, args...)
+}
+
+func (a dbAdapter) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	return a.Exec(query, args...)
+}
+
+func (a dbAdapter) QueryRowContext(_ context.Context, query string, args ...any) *sql.Row {
+	return a.QueryRow(query, args...)
+}
+
+// toDBContext returns conn itself if it is context aware, otherwise wraps it in a dbAdapter.
+func toDBContext(conn DB) DBContext {
+	if c, ok := conn.(DBContext); ok {
+		return c
+	}
+	return dbAdapter{conn}
+}
+
 type DBResult struct {
 	RowsAffected int64 `json:"rows_affected"`
 	LastInsertId int64 `json:"last_insert_id"`
@@ -28,172 +61,222 @@ type DBResult struct {
 
 // QueryToArrays - run sql and return an array of arrays
 func QueryToArrays[T DB](conn T, sqlStatement string, sqlParams ...any) ([]string, [][]any, error) {
-	dbType := GetDbType(conn)
+	return QueryToArraysContext(context.Background(), toDBContext(conn), sqlStatement, sqlParams...)
+}
+
+// QueryToArraysContext - run sql with a context and return an array of arrays
+func QueryToArraysContext[T DBContext](ctx context.Context, conn T, sqlStatement string, sqlParams ...any) ([]string, [][]any, error) {
+	dbType := GetDbTypeContext(ctx, conn)
 	data := [][]any{}
-	rows, err := conn.Query(sqlStatement, sqlParams...)
-	if err != nil {
-		if os.Getenv("env") == "dev" {
-			fmt.Println("Error executing: ", sqlStatement)
-		}
-		return []string{}, data, err
-	}
-	cols, err := rows.Columns()
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, sqlStatement, sqlParams...)
 	if err != nil {
-		return []string{}, data, err
-	}
-	lenCols := len(cols)
-	for i, v := range cols {
-		cols[i] = strings.ToLower(v)
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+		return []string{}, data, queryError(ctx, conn, "QueryToArrays", "", sqlStatement, sqlParams, err)
 	}
-
-	rawResult := make([]any, lenCols)
-	colTypes, err := rows.ColumnTypes()
+	defer rows.Close()
+	cols, scan, err := newRowScanner(rows, dbType)
 	if err != nil {
-		return []string{}, data, err
-	}
-	dest := make([]any, lenCols) // A temporary any slice
-	for i := range rawResult {
-		dest[i] = &rawResult[i] // Put pointers to each string in the interface slice
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+		return []string{}, data, queryError(ctx, conn, "QueryToArrays", "", sqlStatement, sqlParams, err)
 	}
 	for rows.Next() {
-		result := make([]any, lenCols)
-		rows.Scan(dest...)
-		for i, raw := range rawResult {
-			if raw == nil {
-				result[i] = nil
-			} else {
-				colType := colTypes[i].DatabaseTypeName()
-				result[i] = convertBytes(raw, colType)
-				switch dbType {
-				case Oracle:
-					result[i] = convertStrings(raw, colType)
-				case SQLite:
-					// in sqlite, json columns fall here, if columnName contains "json" case insensitively
-					if v, ok := raw.(string); ok {
-						if colType == "" {
-							_v := strings.TrimSpace(v)
-							if strings.HasPrefix(_v, "{") && strings.HasSuffix(_v, "}") || strings.HasPrefix(_v, "[") && strings.HasSuffix(_v, "]") {
-								var a any
-								err := json.Unmarshal([]byte(_v), &a)
-								if err == nil {
-									result[i] = &a
-								}
-							}
-						}
-					}
-				}
-			}
+		result, err := scan()
+		if err != nil {
+			logQuery(ctx, conn, sqlStatement, sqlParams, start, int64(len(data)), err)
+			return cols, data, queryError(ctx, conn, "QueryToArrays", "", sqlStatement, sqlParams, err)
 		}
 		data = append(data, result)
 	}
+	err = rows.Err()
+	logQuery(ctx, conn, sqlStatement, sqlParams, start, int64(len(data)), err)
+	if err != nil {
+		return cols, data, queryError(ctx, conn, "QueryToArrays", "", sqlStatement, sqlParams, err)
+	}
 	return cols, data, nil
 }
 
 // QueryToMaps - run sql and return an array of maps
 func QueryToMaps[T DB](conn T, sqlStatement string, sqlParams ...any) ([]map[string]any, error) {
-	dbType := GetDbType(conn)
+	return QueryToMapsContext(context.Background(), toDBContext(conn), sqlStatement, sqlParams...)
+}
+
+// QueryToMapsContext - run sql with a context and return an array of maps
+func QueryToMapsContext[T DBContext](ctx context.Context, conn T, sqlStatement string, sqlParams ...any) ([]map[string]any, error) {
+	dbType := GetDbTypeContext(ctx, conn)
 	results := []map[string]any{}
-	rows, err := conn.Query(sqlStatement, sqlParams...)
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, sqlStatement, sqlParams...)
 	if err != nil {
-		if os.Getenv("env") == "dev" {
-			fmt.Println("Error executing: ", sqlStatement)
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+		return results, queryError(ctx, conn, "QueryToMaps", "", sqlStatement, sqlParams, err)
+	}
+	defer rows.Close()
+	cols, scan, err := newRowScanner(rows, dbType)
+	if err != nil {
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+		return results, queryError(ctx, conn, "QueryToMaps", "", sqlStatement, sqlParams, err)
+	}
+	for rows.Next() {
+		row, err := scan()
+		if err != nil {
+			logQuery(ctx, conn, sqlStatement, sqlParams, start, int64(len(results)), err)
+			return results, queryError(ctx, conn, "QueryToMaps", "", sqlStatement, sqlParams, err)
 		}
-		return results, err
+		results = append(results, rowToMap(cols, row))
 	}
+	err = rows.Err()
+	logQuery(ctx, conn, sqlStatement, sqlParams, start, int64(len(results)), err)
+	if err != nil {
+		return results, queryError(ctx, conn, "QueryToMaps", "", sqlStatement, sqlParams, err)
+	}
+	return results, nil
+}
+
+// newRowScanner returns the lower cased column names of rows and a function that
+// scans the current row and converts each value according to its column type. Conversion
+// errors name the column.
+func newRowScanner(rows *sql.Rows, dbType DbType) ([]string, func() ([]any, error), error) {
 	cols, err := rows.Columns()
 	if err != nil {
-		return results, err
+		return nil, nil, err
 	}
 	lenCols := len(cols)
-
 	for i, v := range cols {
 		cols[i] = strings.ToLower(v)
 	}
 
-	rawResult := make([]any, lenCols)
 	colTypes, err := rows.ColumnTypes()
 	if err != nil {
-		return results, err
+		return nil, nil, err
+	}
+	colTypeNames := make([]string, lenCols)
+	for i, colType := range colTypes {
+		colTypeNames[i] = colType.DatabaseTypeName()
 	}
+
+	rawResult := make([]any, lenCols)
 	dest := make([]any, lenCols) // A temporary any slice
 	for i := range rawResult {
 		dest[i] = &rawResult[i] // Put pointers to each string in the interface slice
 	}
-	for rows.Next() {
-		result := make(map[string]any, lenCols)
-		rows.Scan(dest...)
+	scan := func() ([]any, error) {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result := make([]any, lenCols)
 		for i, raw := range rawResult {
-			if raw == nil {
-				result[cols[i]] = nil
-			} else {
-				colType := colTypes[i].DatabaseTypeName()
-				result[cols[i]] = convertBytes(raw, colType)
-				switch dbType {
-				case Oracle:
-					result[cols[i]] = convertStrings(raw, colType)
-				case SQLite:
-					// in sqlite, json columns fall here, if columnName contains "json" case insensitively
-					if v, ok := raw.(string); ok {
-						if colType == "" {
-							_v := strings.TrimSpace(v)
-							if strings.HasPrefix(_v, "{") && strings.HasSuffix(_v, "}") || strings.HasPrefix(_v, "[") && strings.HasSuffix(_v, "]") {
-								var a any
-								err := json.Unmarshal([]byte(_v), &a)
-								if err == nil {
-									result[cols[i]] = &a
-								}
-							}
-						}
-					}
-				}
+			value, err := convertValue(raw, colTypeNames[i], dbType)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", cols[i], err)
 			}
+			result[i] = value
 		}
-		results = append(results, result)
+		return result, nil
 	}
-	return results, nil
+	return cols, scan, nil
+}
+
+func rowToMap(cols []string, row []any) map[string]any {
+	result := make(map[string]any, len(cols))
+	for i, col := range cols {
+		result[col] = row[i]
+	}
+	return result
+}
+
+// convertValue converts a raw scanned value to its Go representation.
+func convertValue(raw any, colType string, dbType DbType) (any, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	if dialect := dbType.Dialect(); dialect != nil {
+		return dialect.ConvertValue(raw, colType)
+	}
+	return convertBytes(raw, colType)
 }
 
 // faulty mysql driver workaround https://github.com/go-sql-driver/mysql/issues/1401
-func convertBytes(raw any, colType string) any {
-	if v, ok := raw.([]byte); ok {
-		value := string(v)
-		switch colType {
-		case "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR":
-			raw, _ = strconv.Atoi(value)
-		case "TINYINT", "BOOL", "BOOLEAN", "BIT":
-			raw, _ = strconv.ParseBool(value)
-		case "FLOAT", "DOUBLE", "DECIMAL":
-			raw, _ = strconv.ParseFloat(value, 64)
-		case "DATETIME", "TIMESTAMP":
-			raw, _ = time.Parse("2006-01-02 15:04:05", value)
-		case "DATE":
-			raw, _ = time.Parse("2006-01-02", value)
-		case "TIME":
-			raw, _ = time.Parse("15:04:05", value)
-		case "JSON":
-			raw = json.RawMessage(value)
-		case "NULL":
-			raw = nil
-		default:
-			raw = value
-		}
-	}
-	return raw
+func convertBytes(raw any, colType string) (any, error) {
+	v, ok := raw.([]byte)
+	if !ok {
+		return raw, nil
+	}
+	value := string(v)
+	switch colType {
+	case "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR":
+		return strconv.Atoi(value)
+	case "BIT":
+		// BIT(1) is sent as a single byte
+		if len(v) == 1 && v[0] <= 1 {
+			return v[0] == 1, nil
+		}
+		return strconv.ParseBool(value)
+	case "TINYINT":
+		// the driver doesn't tell TINYINT(1), which holds booleans, from other TINYINT columns,
+		// so all of them are integers, rather than some values of a column being bools
+		return strconv.Atoi(value)
+	case "BOOL", "BOOLEAN":
+		return strconv.ParseBool(value)
+	case "FLOAT", "DOUBLE", "DECIMAL":
+		return strconv.ParseFloat(value, 64)
+	case "DATETIME", "TIMESTAMP":
+		return parseTime("2006-01-02 15:04:05", value)
+	case "DATE":
+		return parseTime("2006-01-02", value)
+	case "TIME":
+		// a TIME within a day is a time of day, others are durations up to 838:59:59
+		if t, err := time.Parse("15:04:05", value); err == nil {
+			return t, nil
+		}
+		return parseDuration(value)
+	case "JSON":
+		return json.RawMessage(value), nil
+	case "NULL":
+		return nil, nil
+	}
+	return value, nil
 }
 
 // faulty oracle driver workaround https://github.com/sijms/go-ora/issues/533
-func convertStrings(raw any, colType string) any {
-	if v, ok := raw.(string); ok {
-		switch colType {
-		case "NUMBER":
-			raw, _ = strconv.ParseFloat(v, 64)
-		case "DATE", "TIMESTAMP":
-			raw, _ = time.Parse("2006-01-02 15:04:05", v)
-		default:
-			raw = v
-		}
+func convertStrings(raw any, colType string) (any, error) {
+	v, ok := raw.(string)
+	if !ok {
+		return raw, nil
+	}
+	switch colType {
+	case "NUMBER":
+		return strconv.ParseFloat(v, 64)
+	case "DATE", "TIMESTAMP":
+		return parseTime("2006-01-02 15:04:05", v)
+	}
+	return v, nil
+}
+
+// parseDuration parses a MySQL TIME like -838:59:59.5 as a time.Duration.
+func parseDuration(value string) (time.Duration, error) {
+	unsigned, negative := strings.CutPrefix(value, "-")
+	parts := strings.Split(unsigned, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid TIME %q", value)
+	}
+	d, err := time.ParseDuration(parts[0] + "h" + parts[1] + "m" + parts[2] + "s")
+	if err != nil {
+		return 0, fmt.Errorf("invalid TIME %q", value)
+	}
+	if negative {
+		d = -d
 	}
-	return raw
+	return d, nil
+}
+
+// parseTime parses value with layout. The zero dates of MySQL, e.g. 0000-00-00, are the
+// zero time.Time.
+func parseTime(layout string, value string) (time.Time, error) {
+	if strings.HasPrefix(value, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	return time.Parse(layout, value)
 }
 
 // isPrimitiveType returns true if the type is a Go primitive, time.Time, or pointer to one of those.
@@ -212,23 +295,64 @@ func isPrimitiveType(t reflect.Type) bool {
 }
 
 func QueryToStructs[T DB, S any](conn T, results *[]S, sqlStatement string, sqlParams ...any) error {
-	rows, err := conn.Query(sqlStatement, sqlParams...)
+	return QueryToStructsContext(context.Background(), toDBContext(conn), results, sqlStatement, sqlParams...)
+}
+
+func QueryToStructsContext[T DBContext, S any](ctx context.Context, conn T, results *[]S, sqlStatement string, sqlParams ...any) error {
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, sqlStatement, sqlParams...)
+	if err != nil {
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+		return queryError(ctx, conn, "QueryToStructs", "", sqlStatement, sqlParams, err)
+	}
+	defer rows.Close()
+	scan, err := newStructScanner[S](rows)
+	if err != nil {
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+		return queryError(ctx, conn, "QueryToStructs", "", sqlStatement, sqlParams, err)
+	}
+	first := len(*results)
+	count := int64(0)
+	for rows.Next() {
+		result, err := scan()
+		if err != nil {
+			logQuery(ctx, conn, sqlStatement, sqlParams, start, count, err)
+			return queryError(ctx, conn, "QueryToStructs", "", sqlStatement, sqlParams, err)
+		}
+		*results = append(*results, result)
+		count++
+	}
+	err = rows.Err()
+	logQuery(ctx, conn, sqlStatement, sqlParams, start, count, err)
 	if err != nil {
-		if os.Getenv("env") == "dev" {
-			fmt.Println("Error executing: ", sqlStatement)
+		return queryError(ctx, conn, "QueryToStructs", "", sqlStatement, sqlParams, err)
+	}
+	// the hooks may run statements on conn, which needs the connection of rows if it's a *sql.Tx
+	rows.Close()
+	for i := first; i < len(*results); i++ {
+		err := runHook(&(*results)[i], func(h AfterRetrieveHook) error {
+			return h.AfterRetrieve(ctx, conn)
+		})
+		if err != nil {
+			return err
 		}
-		return err
 	}
+	return nil
+}
+
+// newStructScanner returns a function that scans the current row of rows into a new S.
+// S is either a struct or a pointer to a struct, columns are matched to fields by the db tag.
+// NULL columns leave the fields that can't hold NULL at their zero value.
+func newStructScanner[S any](rows *sql.Rows) (func() (S, error), error) {
 	cols, err := rows.Columns()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	lenCols := len(cols)
 
-	// Build a mapping from column index to struct field index and type
+	// Build a mapping from column index to struct field index
 	type fieldInfo struct {
-		fieldIndex  int
-		isPrimitive bool
+		fieldIndex int
 	}
 	var (
 		structType reflect.Type
@@ -242,6 +366,22 @@ func QueryToStructs[T DB, S any](conn T, results *[]S, sqlStatement string, sqlP
 		isPtr = false
 		structType = typeS
 	}
+	if m := mapperFor(structType); m != nil {
+		indexes := m.columnIndexes(cols)
+		return func() (S, error) {
+			p := m.newStruct()
+			targets, assign := nullableTargets(m.scanTargets(p, indexes))
+			if err := rows.Scan(targets...); err != nil {
+				var zero S
+				return zero, err
+			}
+			assign()
+			if isPtr {
+				return p.(S), nil
+			}
+			return m.deref(p).(S), nil
+		}, nil
+	}
 	colToField := make([]fieldInfo, lenCols)
 	for colIndex, colName := range cols {
 		found := false
@@ -249,8 +389,7 @@ func QueryToStructs[T DB, S any](conn T, results *[]S, sqlStatement string, sqlP
 			dbTag := structType.Field(fieldIndex).Tag.Get("db")
 			if strings.EqualFold(colName, dbTag) {
 				colToField[colIndex] = fieldInfo{
-					fieldIndex:  fieldIndex,
-					isPrimitive: isPrimitiveType(structType.Field(fieldIndex).Type),
+					fieldIndex: fieldIndex,
 				}
 				found = true
 				break
@@ -258,13 +397,12 @@ func QueryToStructs[T DB, S any](conn T, results *[]S, sqlStatement string, sqlP
 		}
 		if !found {
 			colToField[colIndex] = fieldInfo{
-				fieldIndex:  -1,
-				isPrimitive: false,
+				fieldIndex: -1,
 			}
 		}
 	}
 
-	for rows.Next() {
+	scan := func() (S, error) {
 		var (
 			resultVal reflect.Value
 		)
@@ -278,43 +416,37 @@ func QueryToStructs[T DB, S any](conn T, results *[]S, sqlStatement string, sqlP
 			structValue = resultVal.Elem()
 		}
 		fieldPtrs := make([]any, lenCols)
-		tmpStrings := make([]*string, lenCols) // for non-primitives
 		for colIndex, info := range colToField {
 			if info.fieldIndex == -1 {
 				fieldPtrs[colIndex] = new(any)
 				continue
 			}
-			field := structValue.Field(info.fieldIndex)
-			if info.isPrimitive {
-				fieldPtrs[colIndex] = field.Addr().Interface()
-			} else {
-				tmp := new(string)
-				tmpStrings[colIndex] = tmp
-				fieldPtrs[colIndex] = tmp
-			}
+			// non-primitive fields are decoded from JSON
+			fieldPtrs[colIndex] = ScanTarget(structValue.Field(info.fieldIndex).Addr().Interface())
 		}
-		rows.Scan(fieldPtrs...)
-		// Unmarshal JSON for non-primitive fields
-		for colIndex, info := range colToField {
-			if info.fieldIndex == -1 || info.isPrimitive {
-				continue
-			}
-			field := structValue.Field(info.fieldIndex)
-			tmp := tmpStrings[colIndex]
-			if tmp != nil && *tmp != "" {
-				json.Unmarshal([]byte(*tmp), field.Addr().Interface())
-			}
+		targets, assign := nullableTargets(fieldPtrs)
+		if err := rows.Scan(targets...); err != nil {
+			var zero S
+			return zero, err
 		}
-		*results = append(*results, resultVal.Interface().(S))
+		assign()
+		return resultVal.Interface().(S), nil
 	}
-
-	return nil
+	return scan, nil
 }
 
 func Retrieve[T DB, S any](conn T, result *S, table string) error {
+	return RetrieveContext(context.Background(), toDBContext(conn), result, table)
+}
+
+// RetrieveContext - same as Retrieve, with a context. If result has a field tagged
+// softdelete:"true", rows marked as deleted are not found unless ctx comes from IncludeDeleted.
+// NULL columns set the fields that can't hold NULL to their zero value. ErrNotFound is returned
+// if there is no row.
+func RetrieveContext[T DBContext, S any](ctx context.Context, conn T, result *S, table string) error {
 	fields := StructFieldToDbField(result)
 	_, pkMap := StructToDbMap(result)
-	dbType := GetDbType(conn)
+	dbType := GetDbTypeContext(ctx, conn)
 	if dbType == Unknown {
 		return errors.New("unknown database type")
 	}
@@ -322,57 +454,141 @@ func Retrieve[T DB, S any](conn T, result *S, table string) error {
 	if err != nil {
 		return err
 	}
+	softDelete, ok, err := softDeleteField(result)
+	if err != nil {
+		return err
+	}
+	if ok && !isIncludeDeleted(ctx) {
+		clause, clauseValues := softDelete.notDeletedClause(len(values), dbType)
+		where += " " + clause
+		values = append(values, clauseValues...)
+	}
 	fieldsString := strings.Join(fields, ", ")
 	SqlSafe(&fieldsString)
 	SqlSafe(&table)
 	sqlStatement := fmt.Sprintf("SELECT %s FROM %s WHERE 1=1 %s", fieldsString, table, where)
 
-	rows, err := conn.Query(sqlStatement, values...)
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, sqlStatement, values...)
+	logQuery(ctx, conn, sqlStatement, values, start, -1, err)
 	if err != nil {
-		if os.Getenv("env") == "dev" {
-			fmt.Println("Error executing: ", sqlStatement)
-		}
-		return err
+		return queryError(ctx, conn, "Retrieve", table, sqlStatement, values, err)
 	}
+	defer rows.Close()
 	cols, err := rows.Columns()
 	if err != nil {
-		return err
+		return queryError(ctx, conn, "Retrieve", table, sqlStatement, values, err)
 	}
 	lenCols := len(cols)
 
 	if rows.Next() {
-		colValues := make([]any, lenCols)
-		structValue := reflect.ValueOf(result).Elem()
-		for colIndex, colName := range cols { // iterate through columns
-			found := false
-			for fieldIndex := 0; fieldIndex < structValue.NumField(); fieldIndex++ { // iterate through struct fields
-				dbTag := structValue.Type().Field(fieldIndex).Tag.Get("db")
-				if strings.EqualFold(colName, dbTag) {
-					colValues[colIndex] = structValue.Field(fieldIndex).Addr().Interface()
-					found = true
-					break
+		var colValues []any
+		if m := mapperFor(reflect.TypeFor[S]()); m != nil {
+			colValues = m.scanTargets(result, m.columnIndexes(cols))
+		} else {
+			colValues = make([]any, lenCols)
+			structValue := reflect.ValueOf(result).Elem()
+			for colIndex, colName := range cols { // iterate through columns
+				found := false
+				for fieldIndex := 0; fieldIndex < structValue.NumField(); fieldIndex++ { // iterate through struct fields
+					dbTag := structValue.Type().Field(fieldIndex).Tag.Get("db")
+					if strings.EqualFold(colName, dbTag) {
+						colValues[colIndex] = ScanTarget(structValue.Field(fieldIndex).Addr().Interface())
+						found = true
+						break
+					}
+				}
+				if !found {
+					colValues[colIndex] = new(any)
 				}
 			}
-			if !found {
-				colValues[colIndex] = new(any)
-			}
 		}
-		rows.Scan(colValues...)
+		targets, assign := nullableTargets(colValues)
+		if err := rows.Scan(targets...); err != nil {
+			return queryError(ctx, conn, "Retrieve", table, sqlStatement, values, err)
+		}
+		assign()
 		rows.Close()
-		return nil
+		return runHook(result, func(h AfterRetrieveHook) error {
+			return h.AfterRetrieve(ctx, conn)
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return queryError(ctx, conn, "Retrieve", table, sqlStatement, values, err)
+	}
+	return notFoundError(table, pkMap)
+}
+
+// nullableTargets replaces the scan targets that can't hold NULL by pointers to pointers. assign
+// copies the values scanned into them to the fields, and sets the fields of NULL to zero.
+func nullableTargets(targets []any) (nullable []any, assign func()) {
+	nullable = make([]any, len(targets))
+	var fields, pointers []reflect.Value
+	for i, target := range targets {
+		v := reflect.ValueOf(target)
+		if _, ok := target.(sql.Scanner); ok || v.Kind() != reflect.Pointer {
+			nullable[i] = target
+			continue
+		}
+		switch v.Elem().Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			nullable[i] = target
+			continue
+		}
+		pointer := reflect.New(v.Type())
+		nullable[i] = pointer.Interface()
+		fields = append(fields, v.Elem())
+		pointers = append(pointers, pointer.Elem())
+	}
+	return nullable, func() {
+		for i, field := range fields {
+			if pointers[i].IsNil() {
+				field.SetZero()
+			} else {
+				field.Set(pointers[i].Elem())
+			}
+		}
 	}
-	return fmt.Errorf("no record found for %s, %v", table, pkMap)
 }
 
 func Create[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
-	fieldMap, pkMap := StructToDbMap(data)
-	for k, v := range pkMap {
-		fieldMap[k] = v
+	return CreateContext(context.Background(), toDBContext(conn), data, table)
+}
+
+// CreateContext - same as Create, with a context. If data has a single integer primary key
+// field whose value is zero (or a nil pointer), the key is treated as generated by the
+// database: it's left out of the INSERT and the generated value is written back to the field.
+func CreateContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	err := runHook(data, func(h BeforeCreateHook) error {
+		return h.BeforeCreate(ctx, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, err := createContext(ctx, conn, data, table)
+	if err != nil {
+		return nil, queryTable(err, "Create", table)
+	}
+	err = runHook(data, func(h AfterCreateHook) error {
+		return h.AfterCreate(ctx, conn)
+	})
+	if err != nil {
+		return nil, err
 	}
-	dbType := GetDbType(conn)
+	return result, nil
+}
+
+func createContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	nonPkMap, pkMap := StructToDbMap(data)
+	fieldMap := append(pkMap, nonPkMap...)
+	dbType := GetDbTypeContext(ctx, conn)
 	if dbType == Unknown {
 		return nil, errors.New("unknown database type")
 	}
+	genKey, genField, generated := generatedKeyField(data)
+	if generated {
+		fieldMap.Delete(genKey)
+	}
 	qms, keys, values, err := MapForSqlInsert(fieldMap, dbType)
 	if err != nil {
 		return nil, err
@@ -384,13 +600,76 @@ func Create[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
 		}, nil
 	}
 	SqlSafe(&table)
-	sqlStatement := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, keys, qms)
-	return Exec(conn, sqlStatement, values...)
+	if !generated {
+		sqlStatement := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, keys, qms)
+		return ExecContext(ctx, conn, sqlStatement, values...)
+	}
+
+	SqlSafe(&genKey)
+	var id int64
+	sqlStatement, mode := dbType.Dialect().InsertReturningSql(table, keys, qms, genKey, len(values))
+	switch mode {
+	case ReturningRow:
+		start := time.Now()
+		err := conn.QueryRowContext(ctx, sqlStatement, values...).Scan(&id)
+		logQuery(ctx, conn, sqlStatement, values, start, 1, err)
+		if err != nil {
+			return nil, queryError(ctx, conn, "Create", table, sqlStatement, values, err)
+		}
+	case ReturningOutParam:
+		if _, err := ExecContext(ctx, conn, sqlStatement, append(values, sql.Out{Dest: &id})...); err != nil {
+			return nil, err
+		}
+	default:
+		result, err := ExecContext(ctx, conn, sqlStatement, values...)
+		if err != nil {
+			return nil, err
+		}
+		id = result.LastInsertId
+	}
+	setIntField(genField, id)
+	return &DBResult{
+		RowsAffected: 1,
+		LastInsertId: id,
+	}, nil
 }
 
 func Update[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
+	return UpdateContext(context.Background(), toDBContext(conn), data, table)
+}
+
+// UpdateContext - same as Update, with a context. If data has an integer field tagged
+// version:"true", the row is only updated if its version still matches the field, and the
+// version is incremented. ErrStaleObject is returned if no row matched.
+func UpdateContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	err := runHook(data, func(h BeforeUpdateHook) error {
+		return h.BeforeUpdate(ctx, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, err := updateContext(ctx, conn, data, table)
+	if err != nil {
+		return nil, queryTable(err, "Update", table)
+	}
+	err = runHook(data, func(h AfterUpdateHook) error {
+		return h.AfterUpdate(ctx, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func updateContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
 	nonPkMap, pkMap := StructToDbMap(data)
-	dbType := GetDbType(conn)
+	versionKey, versionField, versioned := taggedField(data, "version")
+	var version int64
+	if versioned {
+		nonPkMap.Delete(versionKey)
+		version = intFieldValue(versionField)
+	}
+	dbType := GetDbTypeContext(ctx, conn)
 	if dbType == Unknown {
 		return nil, errors.New("unknown database type")
 	}
@@ -408,39 +687,86 @@ func Update[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	if versioned {
+		SqlSafe(&versionKey)
+		setClause += fmt.Sprintf(",%s=%s+1", versionKey, versionKey)
+		where += fmt.Sprintf(" AND %s=%s", versionKey, GetPlaceHolder(len(setValues)+len(whereValues), dbType))
+		whereValues = append(whereValues, version)
+	}
 	values := append(setValues, whereValues...)
 	sqlStatement := fmt.Sprintf(`UPDATE %s SET %s WHERE 1=1 %s`, table, setClause, where)
-	return Exec(conn, sqlStatement, values...)
+	result, err := ExecContext(ctx, conn, sqlStatement, values...)
+	if err != nil || !versioned {
+		return result, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: %s, %v", ErrStaleObject, table, pkMap)
+	}
+	setIntField(versionField, version+1)
+	return result, nil
 }
 
 func Delete[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
-	_, pkMap := StructToDbMap(data)
-	dbType := GetDbType(conn)
-	if dbType == Unknown {
-		return nil, errors.New("unknown database type")
+	return DeleteContext(context.Background(), toDBContext(conn), data, table)
+}
+
+// DeleteContext - same as Delete, with a context. If data has an integer field tagged
+// version:"true", the row is only deleted if its version still matches the field.
+// ErrStaleObject is returned if no row matched. If data has a field tagged softdelete:"true",
+// the row is marked as deleted instead, see HardDelete to remove it.
+func DeleteContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	softDelete, ok, err := softDeleteField(data)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return HardDeleteContext(ctx, conn, data, table)
+	}
+	return deleteWithHooks(ctx, conn, data, func() (*DBResult, error) {
+		result, err := softDeleteContext(ctx, conn, data, table, softDelete)
+		return result, queryTable(err, "Delete", table)
+	})
+}
+
+// deleteWithHooks runs del between the delete hooks of data.
+func deleteWithHooks[T DBContext, S any](ctx context.Context, conn T, data *S, del func() (*DBResult, error)) (*DBResult, error) {
+	err := runHook(data, func(h BeforeDeleteHook) error {
+		return h.BeforeDelete(ctx, conn)
+	})
+	if err != nil {
+		return nil, err
 	}
-	where, whereValues, err := MapForSqlWhere(pkMap, 0, dbType)
+	result, err := del()
 	if err != nil {
 		return nil, err
 	}
-	SqlSafe(&table)
-	sqlStatement := fmt.Sprintf(`DELETE FROM %s WHERE 1=1 %s`, table, where)
-	return Exec(conn, sqlStatement, whereValues...)
+	err = runHook(data, func(h AfterDeleteHook) error {
+		return h.AfterDelete(ctx, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // Exec - run sql and return the number of rows affected
 func Exec[T DB](conn T, sqlStatement string, sqlParams ...any) (*DBResult, error) {
-	result, err := conn.Exec(sqlStatement, sqlParams...)
+	return ExecContext(context.Background(), toDBContext(conn), sqlStatement, sqlParams...)
+}
+
+// ExecContext - run sql with a context and return the number of rows affected. Failures are
+// returned as a *QueryError, constraint violations as a *ConstraintError inside it.
+func ExecContext[T DBContext](ctx context.Context, conn T, sqlStatement string, sqlParams ...any) (*DBResult, error) {
+	start := time.Now()
+	result, err := conn.ExecContext(ctx, sqlStatement, sqlParams...)
 	if err != nil {
-		if os.Getenv("env") == "dev" {
-			fmt.Println("Error executing: ", sqlStatement)
-			fmt.Println(err)
-		}
-		return nil, err
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+		return nil, queryError(ctx, conn, "Exec", "", sqlStatement, sqlParams, err)
 	}
 	rowsffected, err := result.RowsAffected()
+	logQuery(ctx, conn, sqlStatement, sqlParams, start, rowsffected, err)
 	if err != nil {
-		return nil, err
+		return nil, queryError(ctx, conn, "Exec", "", sqlStatement, sqlParams, err)
 	}
 	ret := &DBResult{
 		RowsAffected: rowsffected,
@@ -458,6 +784,9 @@ func SqlSafe(s *string) {
 }
 
 func StructFieldToDbField[T any](s *T) (fields []string) {
+	if m := mapperFor(reflect.TypeFor[T]()); m != nil {
+		return slices.Clone(m.columns)
+	}
 	structValue := reflect.ValueOf(s).Elem()
 	for fieldIndex := 0; fieldIndex < structValue.NumField(); fieldIndex++ {
 		fieldTag := structValue.Type().Field(fieldIndex).Tag
@@ -469,9 +798,21 @@ func StructFieldToDbField[T any](s *T) (fields []string) {
 	return
 }
 
-func StructToDbMap[T any](s *T) (nonPkMap map[string]any, pkMap map[string]any) {
-	nonPkMap = make(map[string]any)
-	pkMap = make(map[string]any)
+// StructToDbMap - return the db fields of s in field order, split into the non primary key and
+// the primary key fields. Nil pointer fields are left out.
+func StructToDbMap[T any](s *T) (nonPkMap DbMap, pkMap DbMap) {
+	nonPkMap = DbMap{}
+	pkMap = DbMap{}
+	if m := mapperFor(reflect.TypeFor[T]()); m != nil {
+		m.values(s, func(column string, pk bool, value any) {
+			if pk {
+				pkMap.Set(column, value)
+			} else {
+				nonPkMap.Set(column, value)
+			}
+		})
+		return
+	}
 	structValue := reflect.ValueOf(s).Elem()
 	for fieldIndex := 0; fieldIndex < structValue.NumField(); fieldIndex++ {
 		var field = structValue.Type().Field(fieldIndex)
@@ -493,16 +834,106 @@ func StructToDbMap[T any](s *T) (nonPkMap map[string]any, pkMap map[string]any)
 			continue
 		}
 		if dbTag != "" && pkTag != "true" {
-			nonPkMap[dbTag] = value
+			nonPkMap.Set(dbTag, value)
 		}
 		if pkTag == "true" {
-			pkMap[dbTag] = value
+			pkMap.Set(dbTag, value)
 		}
 	}
 	return
 }
 
-func MapForSqlInsert(m map[string]any, dbType DbType) (placeholders string, keys string, values []any, err error) {
+// generatedKeyField returns the db tag and the field of the primary key of s if it's expected
+// to be generated by the database: s has exactly one primary key field, it's an integer or a
+// pointer to an integer, and it's zero or nil.
+func generatedKeyField[T any](s *T) (dbTag string, field reflect.Value, ok bool) {
+	structValue := reflect.ValueOf(s).Elem()
+	structType := structValue.Type()
+	for fieldIndex := 0; fieldIndex < structType.NumField(); fieldIndex++ {
+		fieldTag := structType.Field(fieldIndex).Tag
+		if fieldTag.Get("pk") != "true" || !structType.Field(fieldIndex).IsExported() {
+			continue
+		}
+		if ok {
+			// composite keys are never generated
+			return "", reflect.Value{}, false
+		}
+		dbTag = fieldTag.Get("db")
+		field = structValue.Field(fieldIndex)
+		ok = true
+	}
+	if !ok {
+		return "", reflect.Value{}, false
+	}
+	value := field
+	if field.Kind() == reflect.Pointer {
+		if !field.IsNil() {
+			return "", reflect.Value{}, false
+		}
+		value = reflect.Zero(field.Type().Elem())
+	}
+	if !isIntKind(value.Kind()) || !value.IsZero() {
+		return "", reflect.Value{}, false
+	}
+	return dbTag, field, true
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// taggedField returns the db tag and the field of the first exported field of s tagged tag:"true".
+func taggedField[T any](s *T, tag string) (dbTag string, field reflect.Value, ok bool) {
+	structValue := reflect.ValueOf(s).Elem()
+	structType := structValue.Type()
+	for fieldIndex := 0; fieldIndex < structType.NumField(); fieldIndex++ {
+		structField := structType.Field(fieldIndex)
+		if structField.IsExported() && structField.Tag.Get(tag) == "true" {
+			return structField.Tag.Get("db"), structValue.Field(fieldIndex), true
+		}
+	}
+	return "", reflect.Value{}, false
+}
+
+// intFieldValue returns the value of an integer field, or a pointer to an integer field. A nil pointer is 0.
+func intFieldValue(field reflect.Value) int64 {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return 0
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint())
+	}
+	return 0
+}
+
+// setIntField sets an integer field, or a pointer to an integer field, to v.
+func setIntField(field reflect.Value, v int64) {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(v))
+	}
+}
+
+func MapForSqlInsert(m DbMap, dbType DbType) (placeholders string, keys string, values []any, err error) {
 	length := len(m)
 	if length == 0 {
 		return
@@ -514,48 +945,44 @@ func MapForSqlInsert(m map[string]any, dbType DbType) (placeholders string, keys
 	placeholders = placeholders[:len(placeholders)-1]
 
 	values = make([]any, length)
-	i := 0
-	for k, v := range m {
-		keys += k + ","
-		values[i] = v
-		i++
+	for i, f := range m {
+		keys += f.Key + ","
+		values[i] = f.Value
 	}
 	keys = keys[:len(keys)-1]
 	SqlSafe(&keys)
 	return
 }
 
-func MapForSqlUpdate(m map[string]any, dbType DbType) (set string, values []any, err error) {
+func MapForSqlUpdate(m DbMap, dbType DbType) (set string, values []any, err error) {
 	length := len(m)
 	if length == 0 {
 		return
 	}
 
 	values = make([]any, length)
-	i := 0
-	for k, v := range m {
-		set += fmt.Sprintf("%s=%s,", k, GetPlaceHolder(i, dbType))
-		values[i] = v
-		i++
+	for i, f := range m {
+		set += fmt.Sprintf("%s=%s,", f.Key, GetPlaceHolder(i, dbType))
+		values[i] = f.Value
 	}
 	set = set[:len(set)-1]
 	SqlSafe(&set)
 	return
 }
 
-func MapForSqlWhere(m map[string]any, startIndex int, dbType DbType) (where string, values []any, err error) {
+func MapForSqlWhere(m DbMap, startIndex int, dbType DbType) (where string, values []any, err error) {
 	length := len(m)
 	if length == 0 {
 		return
 	}
 
 	i := startIndex
-	for k, v := range m {
-		if strings.HasPrefix(k, ".") {
+	for _, f := range m {
+		if strings.HasPrefix(f.Key, ".") {
 			continue
 		}
-		where += fmt.Sprintf("AND %s=%s ", k, GetPlaceHolder(i, dbType))
-		values = append(values, v)
+		where += fmt.Sprintf("AND %s=%s ", f.Key, GetPlaceHolder(i, dbType))
+		values = append(values, f.Value)
 		i++
 	}
 	where = strings.TrimSpace(where)
@@ -564,136 +991,34 @@ func MapForSqlWhere(m map[string]any, startIndex int, dbType DbType) (where stri
 }
 
 func GetPlaceHolder(index int, dbType DbType) string {
-	switch dbType {
-	case PostgreSQL:
-		return fmt.Sprintf("$%d", index+1)
-	case SQLServer:
-		return fmt.Sprintf("@p%d", index+1)
-	case Oracle:
-		return fmt.Sprintf(":%d", index+1)
-	default:
-		return "?"
+	if dialect := dbType.Dialect(); dialect != nil {
+		return dialect.Placeholder(index)
 	}
+	return "?"
 }
 
-type DbType int
-
-const (
-	Unknown DbType = iota
-	SQLite
-	MySQL
-	PostgreSQL
-	SQLServer
-	Oracle
-)
-
-var dbTypeMap = map[string]DbType{}
-var mutex = sync.RWMutex{}
-
+// GetDbType - return the type of database conn is connected to. The dialect bound with Open or
+// WithDialect is used if there is one, otherwise it's inferred from the driver of a *sql.DB or
+// *sql.Conn, and as a last resort by probing the database with version queries. A *sql.Tx
+// started with Begin or BeginTx has the type of its parent.
 func GetDbType(conn DB) DbType {
-	connPtrStr := fmt.Sprintf("%p\n", conn)
-	if val, ok := dbTypeMap[connPtrStr]; ok {
-		return val
-	}
-
-	var v string
-	err := conn.QueryRow("SELECT VERSION() AS version").Scan(&v)
-	if err == nil {
-		if strings.Contains(strings.ToLower(v), "postgres") {
-			mutex.Lock()
-			dbTypeMap[connPtrStr] = PostgreSQL
-			mutex.Unlock()
-			return PostgreSQL
-		} else {
-			mutex.Lock()
-			dbTypeMap[connPtrStr] = MySQL
-			mutex.Unlock()
-			return MySQL
-		}
-	}
+	return GetDbTypeContext(context.Background(), toDBContext(conn))
+}
 
-	err = conn.QueryRow("SELECT @@VERSION AS version").Scan(&v)
-	if err == nil {
-		if strings.Contains(strings.ToLower(v), "microsoft") {
-			mutex.Lock()
-			dbTypeMap[connPtrStr] = SQLServer
-			mutex.Unlock()
-			return SQLServer
-		} else {
-			mutex.Lock()
-			dbTypeMap[connPtrStr] = MySQL
-			mutex.Unlock()
-			return MySQL
-		}
+// GetDbTypeContext - same as GetDbType, but the probing queries respect ctx.
+// A probe cancelled by ctx is not cached, so the next call probes again.
+func GetDbTypeContext(ctx context.Context, conn DBContext) DbType {
+	if val, ok := cachedDbType(conn); ok {
+		return val
 	}
 
-	err = conn.QueryRow("SELECT BANNER FROM v$version").Scan(&v)
-	if err == nil {
-		if strings.Contains(strings.ToLower(v), "oracle") {
-			mutex.Lock()
-			dbTypeMap[connPtrStr] = Oracle
-			mutex.Unlock()
-			return Oracle
+	dbType := dbTypeFromConn(ctx, conn)
+	if dbType == Unknown {
+		dbType = probeDbType(ctx, conn)
+		if ctx.Err() != nil {
+			return dbType
 		}
 	}
-	err = conn.QueryRow("SELECT sqlite_version()").Scan(&v)
-	if err == nil {
-		mutex.Lock()
-		dbTypeMap[connPtrStr] = SQLite
-		mutex.Unlock()
-		return SQLite
-	}
-
-	mutex.Lock()
-	dbTypeMap[connPtrStr] = Unknown
-	mutex.Unlock()
-	return Unknown
-}
-
-// func GetAllTables(conn DB) (tables []string, err error) {
-// 	dbType := GetDbType(conn)
-// 	if dbType == PostgreSQL {
-// 		tableMaps, err := QueryToMaps(conn, "SELECT table_name FROM information_schema.tables WHERE table_schema='public'")
-// 	} else if dbType == SQLServer {
-// 		tableMaps, err := QueryToMaps(conn, "SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE='BASE TABLE'")
-// 	} else if dbType == Oracle {
-// 		tableMaps, err := QueryToMaps(conn, "SELECT table_name FROM user_tables")
-// 	} else if dbType == SQLite {
-// 		tableMaps, err := QueryToMaps(conn, "SELECT name FROM sqlite_master WHERE type='table'")
-// 	} else {
-// 		tableMaps, err := QueryToMaps(conn, "SHOW TABLES")
-// 	}
-// 	return
-// }
-
-// func GetTableColumns(conn DB, tableName string) (columns []string, err error) {
-// 	dbType := GetDbType(conn)
-// 	if dbType == PostgreSQL {
-// 		columnMaps, err := QueryToMaps(conn, "SELECT column_name FROM information_schema.columns WHERE table_name=$1", tableName)
-// 	} else if dbType == SQLServer {
-// 		columnMaps, err := QueryToMaps(conn, "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME=$1", tableName)
-// 	} else if dbType == Oracle {
-// 		columnMaps, err := QueryToMaps(conn, "SELECT column_name FROM user_tab_columns WHERE table_name=$1", tableName)
-// 	} else if dbType == SQLite {
-// 		columnMaps, err := QueryToMaps(conn, "PRAGMA table_info($1)", tableName)
-// 	} else {
-// 		columnMaps, err := QueryToMaps(conn, "SHOW COLUMNS FROM $1", tableName)
-// 	}
-// 	return
-// }
-
-// func GetTablePrimaryKeys(conn DB, tableName string) (primaryKeys []string, err error) {
-// 	dbType := GetDbType(conn)
-// 	if dbType == PostgreSQL {
-// 		pkMaps, err := QueryToMaps(conn, "SELECT column_name FROM information_schema.key_column_usage WHERE table_name=$1 AND constraint_name='PRIMARY KEY'", tableName)
-// 	} else if dbType == SQLServer {
-// 		pkMaps, err := QueryToMaps(conn, "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_NAME=$1 AND CONSTRAINT_NAME='PRIMARY'", tableName)
-// 	} else if dbType == Oracle {
-// 		pkMaps, err := QueryToMaps(conn, "SELECT column_name FROM user_cons_columns WHERE table_name=$1 AND constraint_name=(SELECT constraint_name FROM user_constraints WHERE table_name=$1 AND constraint_type='P')", tableName)
-// 	} else if dbType == SQLite {
-// 		pkMaps, err := QueryToMaps(conn, "PRAGMA table_info($1)", tableName)
-// 	} else {
-// 		pkMaps, err := QueryToMaps(conn, "SHOW KEYS FROM $1 WHERE Key_name='PRIMARY'", tableName)
-// 	}
-// 	return
-// }
+	cacheDbType(conn, dbType)
+	return dbType
+}
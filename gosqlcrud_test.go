@@ -1,8 +1,10 @@
 package gosqlcrud
 
 import (
+	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	_ "modernc.org/sqlite"
@@ -181,6 +183,213 @@ func TestQueries(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestQueriesContext(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	_, err = ExecContext(ctx, db, "CREATE TABLE test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+
+	name := "Alpha"
+	result, err := CreateContext(ctx, db, &Test{Id: 1, Name: &name}, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	resultStruct := Test{Id: 1}
+	err = RetrieveContext(ctx, db, &resultStruct, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alpha", *resultStruct.Name)
+
+	resultMaps, err := QueryToMapsContext(ctx, db, "SELECT * FROM test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alpha", resultMaps[0]["name"])
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = QueryToMapsContext(cancelled, db, "SELECT * FROM test")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = ExecContext(cancelled, db, "DELETE FROM test")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, SQLite, GetDbTypeContext(ctx, db))
+
+	result, err = DeleteContext(ctx, db, &Test{Id: 1}, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+}
+
+func TestCreateGeneratedKey(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = Exec(db, "CREATE TABLE test (ID INTEGER PRIMARY KEY AUTOINCREMENT, NAME TEXT)")
+	assert.NoError(t, err)
+
+	name := "Alpha"
+	data := Test{Name: &name}
+	result, err := Create(db, &data, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.LastInsertId)
+	assert.Equal(t, 1, data.Id)
+
+	data = Test{Name: &name}
+	_, err = Create(db, &data, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, data.Id)
+
+	type PtrKey struct {
+		Id   *int64 `db:"ID" pk:"true"`
+		Name string `db:"NAME"`
+	}
+	ptrKey := PtrKey{Name: "Beta"}
+	_, err = Create(db, &ptrKey, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), *ptrKey.Id)
+
+	// a key set by the caller is inserted as is
+	data = Test{Id: 10, Name: &name}
+	result, err = Create(db, &data, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), result.LastInsertId)
+	assert.Equal(t, 10, data.Id)
+}
+
+func TestVersion(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	type Doc struct {
+		Id      int    `db:"ID" pk:"true"`
+		Title   string `db:"TITLE"`
+		Version int    `db:"VERSION" version:"true"`
+	}
+	_, err = Exec(db, "CREATE TABLE doc (ID INTEGER PRIMARY KEY, TITLE TEXT, VERSION INTEGER NOT NULL)")
+	assert.NoError(t, err)
+
+	doc := Doc{Id: 1, Title: "draft", Version: 1}
+	_, err = Create(db, &doc, "doc")
+	assert.NoError(t, err)
+
+	editor1 := Doc{Id: 1}
+	assert.NoError(t, Retrieve(db, &editor1, "doc"))
+	editor2 := Doc{Id: 1}
+	assert.NoError(t, Retrieve(db, &editor2, "doc"))
+
+	editor1.Title = "first"
+	result, err := Update(db, &editor1, "doc")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	assert.Equal(t, 2, editor1.Version)
+
+	editor2.Title = "second"
+	_, err = Update(db, &editor2, "doc")
+	assert.ErrorIs(t, err, ErrStaleObject)
+	assert.Equal(t, 1, editor2.Version)
+
+	_, err = Delete(db, &editor2, "doc")
+	assert.ErrorIs(t, err, ErrStaleObject)
+
+	stored := Doc{Id: 1}
+	assert.NoError(t, Retrieve(db, &stored, "doc"))
+	assert.Equal(t, Doc{Id: 1, Title: "first", Version: 2}, stored)
+
+	result, err = Delete(db, &editor1, "doc")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+}
+
+func TestScanErrors(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = Exec(db, "CREATE TABLE scan_test (ID INTEGER PRIMARY KEY, COUNT INT, TAGS TEXT)")
+	assert.NoError(t, err)
+	_, err = Exec(db, "INSERT INTO scan_test (ID, COUNT, TAGS) VALUES (1, X'6162', '{')")
+	assert.NoError(t, err)
+
+	type ScanTest struct {
+		Id   int               `db:"ID" pk:"true"`
+		Tags map[string]string `db:"TAGS"`
+	}
+	type CountTest struct {
+		Id    int `db:"ID" pk:"true"`
+		Count int `db:"COUNT"`
+	}
+
+	// the rows are closed on every error, the single connection stays usable
+	_, err = QueryToMaps(db, "SELECT * FROM scan_test")
+	assert.ErrorContains(t, err, "column count")
+	_, _, err = QueryToArrays(db, "SELECT * FROM scan_test")
+	assert.ErrorContains(t, err, "column count")
+	var results []ScanTest
+	err = QueryToStructs(db, &results, "SELECT ID, TAGS FROM scan_test")
+	assert.ErrorContains(t, err, `name "TAGS"`)
+	var counts []CountTest
+	err = QueryToStructs(db, &counts, "SELECT ID, COUNT FROM scan_test")
+	assert.ErrorContains(t, err, `name "COUNT"`)
+	err = Retrieve(db, &ScanTest{Id: 1}, "scan_test")
+	assert.ErrorContains(t, err, `name "TAGS"`)
+	assert.Equal(t, 0, db.Stats().InUse)
+
+	_, err = Exec(db, "UPDATE scan_test SET COUNT=NULL, TAGS=NULL")
+	assert.NoError(t, err)
+	// NULL doesn't leave the value the struct held before
+	count := CountTest{Id: 1, Count: 5}
+	assert.NoError(t, Retrieve(db, &count, "scan_test"))
+	assert.Equal(t, 0, count.Count)
+	tagged := ScanTest{Id: 1, Tags: map[string]string{"stale": "tag"}}
+	assert.NoError(t, Retrieve(db, &tagged, "scan_test"))
+	assert.Nil(t, tagged.Tags)
+	err = QueryToStructs(db, &results, "SELECT ID, TAGS FROM scan_test")
+	assert.NoError(t, err)
+	assert.Equal(t, []ScanTest{{Id: 1}}, results)
+	// NULL leaves non-pointer fields at their zero value
+	counts = nil
+	err = QueryToStructs(db, &counts, "SELECT ID, COUNT FROM scan_test")
+	assert.NoError(t, err)
+	assert.Equal(t, []CountTest{{Id: 1}}, counts)
+	for count, err := range QueryToStructsSeq[*sql.DB, CountTest](db, "SELECT ID, COUNT FROM scan_test") {
+		assert.NoError(t, err)
+		assert.Equal(t, CountTest{Id: 1}, count)
+	}
+	assert.Equal(t, 0, db.Stats().InUse)
+}
+
+func TestConvertBytes(t *testing.T) {
+	value, err := convertBytes([]byte{1}, "BIT")
+	assert.NoError(t, err)
+	assert.Equal(t, true, value)
+	value, err = convertBytes([]byte("0000-00-00 00:00:00"), "DATETIME")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Time{}, value)
+	value, err = convertBytes([]byte("1.5"), "DECIMAL")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, value)
+	value, err = convertBytes([]byte("5"), "TINYINT")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, value)
+	value, err = convertBytes([]byte("1"), "TINYINT")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	value, err = convertBytes([]byte("12:30:00"), "TIME")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(0, 1, 1, 12, 30, 0, 0, time.UTC), value)
+	value, err = convertBytes([]byte("-838:59:59.5"), "TIME")
+	assert.NoError(t, err)
+	assert.Equal(t, -(838*time.Hour + 59*time.Minute + 59500*time.Millisecond), value)
+	_, err = convertBytes([]byte("noon"), "TIME")
+	assert.Error(t, err)
+	_, err = convertBytes([]byte("yes"), "BOOLEAN")
+	assert.Error(t, err)
+	_, err = convertBytes([]byte("2024-13-01"), "DATE")
+	assert.Error(t, err)
+	_, err = convertStrings("1,5", "NUMBER")
+	assert.Error(t, err)
+}
+
 func TestReflect(t *testing.T) {
 	name := "test"
 	test := Test{Id: 1, Name: &name}
@@ -190,8 +399,10 @@ func TestReflect(t *testing.T) {
 	assert.Equal(t, "NAME", fields[1])
 
 	nonPkMap, pkMap := StructToDbMap(&test)
-	assert.Equal(t, "test", *nonPkMap["NAME"].(*string))
-	assert.Equal(t, 1, pkMap["ID"])
+	nameValue, _ := nonPkMap.Get("NAME")
+	assert.Equal(t, "test", *nameValue.(*string))
+	idValue, _ := pkMap.Get("ID")
+	assert.Equal(t, 1, idValue)
 }
 
 func TestSqlSafe(t *testing.T) {
@@ -0,0 +1,56 @@
+package gosqlcrud
+
+import "context"
+
+// Hooks are optional methods of the structs passed to Create, Update, Delete, HardDelete,
+// Retrieve and QueryToStructs. They receive the connection of the operation, so a hook can
+// run its own statements in the same transaction. An error from a Before hook aborts the
+// operation before any statement is run, an error from an After hook is returned as the
+// error of the operation, after the statement has run.
+
+// BeforeCreateHook is called by Create before the row is inserted.
+type BeforeCreateHook interface {
+	BeforeCreate(ctx context.Context, conn DBContext) error
+}
+
+// AfterCreateHook is called by Create after the row is inserted and the generated key set.
+type AfterCreateHook interface {
+	AfterCreate(ctx context.Context, conn DBContext) error
+}
+
+// BeforeUpdateHook is called by Update before the row is updated.
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, conn DBContext) error
+}
+
+// AfterUpdateHook is called by Update after the row is updated.
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context, conn DBContext) error
+}
+
+// BeforeDeleteHook is called by Delete and HardDelete before the row is deleted.
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context, conn DBContext) error
+}
+
+// AfterDeleteHook is called by Delete and HardDelete after the row is deleted.
+type AfterDeleteHook interface {
+	AfterDelete(ctx context.Context, conn DBContext) error
+}
+
+// AfterRetrieveHook is called by Retrieve and QueryToStructs for every struct read, after the
+// rows are closed. The Seq iterators don't call it, as their rows are open during the loop.
+type AfterRetrieveHook interface {
+	AfterRetrieve(ctx context.Context, conn DBContext) error
+}
+
+// runHook calls call if data, or *data if S is a pointer, implements the hook H.
+func runHook[H any, S any](data *S, call func(H) error) error {
+	if h, ok := any(data).(H); ok {
+		return call(h)
+	}
+	if h, ok := any(*data).(H); ok {
+		return call(h)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+type hookUser struct {
+	Id       int    `db:"ID" pk:"true"`
+	Email    string `db:"EMAIL"`
+	Loaded   bool
+	readOnly bool
+}
+
+func (u *hookUser) BeforeCreate(ctx context.Context, conn DBContext) error {
+	u.Email = strings.ToLower(u.Email)
+	return nil
+}
+
+func (u *hookUser) AfterCreate(ctx context.Context, conn DBContext) error {
+	_, err := ExecContext(ctx, conn, "INSERT INTO hook_audit (USER_ID, ACTION) VALUES (?, ?)", u.Id, "create")
+	return err
+}
+
+func (u *hookUser) BeforeUpdate(ctx context.Context, conn DBContext) error {
+	if u.Email == "" {
+		return errors.New("email is required")
+	}
+	return nil
+}
+
+func (u *hookUser) BeforeDelete(ctx context.Context, conn DBContext) error {
+	if u.readOnly {
+		return errors.New("read only")
+	}
+	return nil
+}
+
+func (u *hookUser) AfterDelete(ctx context.Context, conn DBContext) error {
+	_, err := ExecContext(ctx, conn, "INSERT INTO hook_audit (USER_ID, ACTION) VALUES (?, ?)", u.Id, "delete")
+	return err
+}
+
+func (u *hookUser) AfterRetrieve(ctx context.Context, conn DBContext) error {
+	u.Loaded = true
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = Exec(db, "CREATE TABLE hook_user (ID INTEGER PRIMARY KEY, EMAIL TEXT)")
+	assert.NoError(t, err)
+	_, err = Exec(db, "CREATE TABLE hook_audit (USER_ID INTEGER, ACTION TEXT)")
+	assert.NoError(t, err)
+
+	// hooks run on the connection of the operation
+	tx, err := Begin(db)
+	assert.NoError(t, err)
+	user := hookUser{Email: "Alice@Example.com"}
+	_, err = Create(tx, &user, "hook_user")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.Id)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.NoError(t, tx.Commit())
+
+	audit, err := QueryToMaps(db, "SELECT * FROM hook_audit")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"user_id": int64(1), "action": "create"}}, audit)
+
+	// a Before hook error aborts the update
+	user.Email = ""
+	_, err = Update(db, &user, "hook_user")
+	assert.EqualError(t, err, "email is required")
+
+	retrieved := hookUser{Id: 1}
+	err = Retrieve(db, &retrieved, "hook_user")
+	assert.NoError(t, err)
+	assert.True(t, retrieved.Loaded)
+	assert.Equal(t, "alice@example.com", retrieved.Email)
+
+	var users []hookUser
+	err = QueryToStructs(db, &users, "SELECT * FROM hook_user")
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.True(t, users[0].Loaded)
+
+	var userPtrs []*hookUser
+	err = QueryToStructs(db, &userPtrs, "SELECT * FROM hook_user")
+	assert.NoError(t, err)
+	assert.Len(t, userPtrs, 1)
+	assert.True(t, userPtrs[0].Loaded)
+
+	retrieved.readOnly = true
+	_, err = Delete(db, &retrieved, "hook_user")
+	assert.EqualError(t, err, "read only")
+	retrieved.readOnly = false
+	result, err := Delete(db, &retrieved, "hook_user")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	audit, err = QueryToMaps(db, "SELECT ACTION FROM hook_audit ORDER BY rowid")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"action": "create"}, {"action": "delete"}}, audit)
+}
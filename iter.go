@@ -0,0 +1,105 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"time"
+)
+
+// QueryToArraysSeq - run sql and return an iterator over the rows as arrays.
+// If cols is not nil, it is set to the lower cased column names before the first row is yielded.
+// The rows are fetched one at a time and closed when the loop ends, including on break.
+func QueryToArraysSeq[T DB](conn T, cols *[]string, sqlStatement string, sqlParams ...any) iter.Seq2[[]any, error] {
+	return QueryToArraysSeqContext(context.Background(), toDBContext(conn), cols, sqlStatement, sqlParams...)
+}
+
+// QueryToArraysSeqContext - same as QueryToArraysSeq, with a context.
+func QueryToArraysSeqContext[T DBContext](ctx context.Context, conn T, cols *[]string, sqlStatement string, sqlParams ...any) iter.Seq2[[]any, error] {
+	dbType := GetDbTypeContext(ctx, conn)
+	return rowsSeq(ctx, conn, "QueryToArraysSeq", sqlStatement, sqlParams, func(rows *sql.Rows) (func() ([]any, error), error) {
+		columns, scan, err := newRowScanner(rows, dbType)
+		if err != nil {
+			return nil, err
+		}
+		if cols != nil {
+			*cols = columns
+		}
+		return scan, nil
+	})
+}
+
+// QueryToMapsSeq - run sql and return an iterator over the rows as maps.
+// The rows are fetched one at a time and closed when the loop ends, including on break.
+func QueryToMapsSeq[T DB](conn T, sqlStatement string, sqlParams ...any) iter.Seq2[map[string]any, error] {
+	return QueryToMapsSeqContext(context.Background(), toDBContext(conn), sqlStatement, sqlParams...)
+}
+
+// QueryToMapsSeqContext - same as QueryToMapsSeq, with a context.
+func QueryToMapsSeqContext[T DBContext](ctx context.Context, conn T, sqlStatement string, sqlParams ...any) iter.Seq2[map[string]any, error] {
+	dbType := GetDbTypeContext(ctx, conn)
+	return rowsSeq(ctx, conn, "QueryToMapsSeq", sqlStatement, sqlParams, func(rows *sql.Rows) (func() (map[string]any, error), error) {
+		cols, scan, err := newRowScanner(rows, dbType)
+		if err != nil {
+			return nil, err
+		}
+		return func() (map[string]any, error) {
+			row, err := scan()
+			if err != nil {
+				return nil, err
+			}
+			return rowToMap(cols, row), nil
+		}, nil
+	})
+}
+
+// QueryToStructsSeq - run sql and return an iterator over the rows as structs.
+// The rows are fetched one at a time and closed when the loop ends, including on break.
+func QueryToStructsSeq[T DB, S any](conn T, sqlStatement string, sqlParams ...any) iter.Seq2[S, error] {
+	return QueryToStructsSeqContext[DBContext, S](context.Background(), toDBContext(conn), sqlStatement, sqlParams...)
+}
+
+// QueryToStructsSeqContext - same as QueryToStructsSeq, with a context.
+func QueryToStructsSeqContext[T DBContext, S any](ctx context.Context, conn T, sqlStatement string, sqlParams ...any) iter.Seq2[S, error] {
+	return rowsSeq(ctx, conn, "QueryToStructsSeq", sqlStatement, sqlParams, newStructScanner[S])
+}
+
+// rowsSeq runs the query of op lazily when the sequence is iterated. prepare is called once
+// with the rows and returns the function that converts the current row. After an error
+// is yielded the iteration stops.
+func rowsSeq[R any](ctx context.Context, conn DBContext, op string, sqlStatement string, sqlParams []any, prepare func(rows *sql.Rows) (func() (R, error), error)) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		var zero R
+		start := time.Now()
+		rows, err := conn.QueryContext(ctx, sqlStatement, sqlParams...)
+		if err != nil {
+			logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+			yield(zero, queryError(ctx, conn, op, "", sqlStatement, sqlParams, err))
+			return
+		}
+		defer rows.Close()
+		scan, err := prepare(rows)
+		if err != nil {
+			logQuery(ctx, conn, sqlStatement, sqlParams, start, -1, err)
+			yield(zero, queryError(ctx, conn, op, "", sqlStatement, sqlParams, err))
+			return
+		}
+		count := int64(0)
+		for rows.Next() {
+			result, err := scan()
+			if err != nil {
+				logQuery(ctx, conn, sqlStatement, sqlParams, start, count, err)
+				err = queryError(ctx, conn, op, "", sqlStatement, sqlParams, err)
+			}
+			if !yield(result, err) || err != nil {
+				return
+			}
+			count++
+		}
+		err = rows.Err()
+		logQuery(ctx, conn, sqlStatement, sqlParams, start, count, err)
+		if err != nil {
+			yield(zero, queryError(ctx, conn, op, "", sqlStatement, sqlParams, err))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package gosqlcrud
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestQuerySeq(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = Exec(db, "CREATE TABLE test (ID INTEGER PRIMARY KEY, NAME TEXT, DATA)")
+	assert.NoError(t, err)
+	_, err = Exec(db, `INSERT INTO test (ID, NAME, DATA) VALUES (1, 'Alpha', '{"a":1}'), (2, 'Beta', NULL), (3, 'Gamma', NULL)`)
+	assert.NoError(t, err)
+
+	cols := []string{}
+	ids := []any{}
+	for row, err := range QueryToArraysSeq(db, &cols, "SELECT ID, NAME FROM test ORDER BY ID") {
+		assert.NoError(t, err)
+		ids = append(ids, row[0])
+	}
+	assert.Equal(t, []string{"id", "name"}, cols)
+	assert.Equal(t, []any{int64(1), int64(2), int64(3)}, ids)
+
+	for row, err := range QueryToMapsSeq(db, "SELECT * FROM test WHERE ID = ?", 1) {
+		assert.NoError(t, err)
+		assert.Equal(t, "Alpha", row["name"])
+		assert.Equal(t, map[string]any{"a": float64(1)}, *row["data"].(*any))
+	}
+
+	names := []string{}
+	for row, err := range QueryToStructsSeq[*sql.DB, Test](db, "SELECT ID, NAME FROM test ORDER BY ID") {
+		assert.NoError(t, err)
+		names = append(names, *row.Name)
+		if row.Id == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"Alpha", "Beta"}, names)
+	// rows are closed on break, so the only connection is back in the pool
+	assert.Equal(t, 0, db.Stats().InUse)
+
+	for _, err := range QueryToMapsSeq(db, "SELECT * FROM missing") {
+		assert.Error(t, err)
+	}
+}
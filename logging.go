@@ -0,0 +1,161 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	logger     *slog.Logger
+	redactArgs bool
+	logMutex   = sync.RWMutex{}
+)
+
+// SetLogger - set the logger of all statements run by this package, nil turns logging off.
+// Successful statements are logged at debug level, failed ones at error level. Without a
+// logger, failures are logged to slog.Default() if the env environment variable is "dev".
+// A connection wrapped with WithLogger uses its own logger instead.
+func SetLogger(l *slog.Logger) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logger = l
+}
+
+// SetRedactArgs - if redact is true, the global logger only logs the number of bound
+// parameters, not their values.
+func SetRedactArgs(redact bool) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	redactArgs = redact
+}
+
+// LoggedDB is a connection that logs the statements run through this package with its own logger.
+type LoggedDB struct {
+	conn       DBContext
+	logger     *slog.Logger
+	redactArgs bool
+}
+
+// WithLogger - wrap conn so statements run on it by this package are logged with l. If
+// redactArgs is true, only the number of bound parameters is logged.
+func WithLogger(conn DBContext, l *slog.Logger, redactArgs bool) *LoggedDB {
+	return &LoggedDB{
+		conn:       conn,
+		logger:     l,
+		redactArgs: redactArgs,
+	}
+}
+
+func (l *LoggedDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return l.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (l *LoggedDB) Exec(query string, args ...any) (sql.Result, error) {
+	return l.conn.ExecContext(context.Background(), query, args...)
+}
+
+func (l *LoggedDB) QueryRow(query string, args ...any) *sql.Row {
+	return l.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+func (l *LoggedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return l.conn.QueryContext(ctx, query, args...)
+}
+
+func (l *LoggedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return l.conn.ExecContext(ctx, query, args...)
+}
+
+func (l *LoggedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return l.conn.QueryRowContext(ctx, query, args...)
+}
+
+func (l *LoggedDB) unwrap() DBContext {
+	return l.conn
+}
+
+func (l *LoggedDB) withTx(tx *sql.Tx) DBContext {
+	var conn DBContext = tx
+	if w, ok := l.conn.(wrappedConn); ok {
+		conn = w.withTx(tx)
+	}
+	return WithLogger(conn, l.logger, l.redactArgs)
+}
+
+// wrappedConn is implemented by connection wrappers of this package.
+type wrappedConn interface {
+	// unwrap returns the wrapped connection.
+	unwrap() DBContext
+	// withTx returns the same wrappers around tx, a transaction begun on the wrapped connection.
+	withTx(tx *sql.Tx) DBContext
+}
+
+// unwrapConn returns the innermost connection of conn.
+func unwrapConn(conn any) any {
+	for {
+		conn = unwrapAdapter(conn)
+		w, ok := conn.(wrappedConn)
+		if !ok {
+			return conn
+		}
+		conn = w.unwrap()
+	}
+}
+
+// loggerFor returns the logger for statements run on conn, nil if they aren't logged.
+func loggerFor(conn any) (*slog.Logger, bool) {
+	for {
+		conn = unwrapAdapter(conn)
+		if l, ok := conn.(*LoggedDB); ok {
+			return l.logger, l.redactArgs
+		}
+		w, ok := conn.(wrappedConn)
+		if !ok {
+			break
+		}
+		conn = w.unwrap()
+	}
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if logger == nil && os.Getenv("env") == "dev" {
+		return slog.Default(), redactArgs
+	}
+	return logger, redactArgs
+}
+
+// logQuery logs a statement that started at start. rows is the number of rows affected or
+// returned, -1 if unknown.
+func logQuery(ctx context.Context, conn any, sqlStatement string, args []any, start time.Time, rows int64, err error) {
+	l, redact := loggerFor(conn)
+	if l == nil {
+		return
+	}
+	level := slog.LevelDebug
+	msg := "sql executed"
+	if err != nil {
+		level = slog.LevelError
+		msg = "sql failed"
+	}
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("sql", sqlStatement),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("arg_count", len(args)),
+	}
+	if !redact {
+		attrs = append(attrs, slog.Any("args", args))
+	}
+	if rows >= 0 {
+		attrs = append(attrs, slog.Int64("rows", rows))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,74 @@
+package gosqlcrud
+
+import (
+	"bytes"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestLogger(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+
+	_, err = Exec(db, "CREATE TABLE log_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+	result, err := Exec(db, "INSERT INTO log_test (ID, NAME) VALUES (?, ?)", 1, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	assert.Contains(t, buf.String(), "level=DEBUG msg=\"sql executed\"")
+	assert.Contains(t, buf.String(), "rows=1")
+	assert.Contains(t, buf.String(), "secret")
+
+	buf.Reset()
+	SetRedactArgs(true)
+	defer SetRedactArgs(false)
+	maps, err := QueryToMaps(db, "SELECT * FROM log_test WHERE NAME=?", "secret")
+	assert.NoError(t, err)
+	assert.Len(t, maps, 1)
+	assert.Contains(t, buf.String(), "arg_count=1")
+	assert.Contains(t, buf.String(), "rows=1")
+	assert.NotContains(t, buf.String(), "secret")
+
+	buf.Reset()
+	_, _, err = QueryToArrays(db, "SELECT * FROM no_such_table")
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), "level=ERROR msg=\"sql failed\"")
+	assert.Contains(t, buf.String(), "no_such_table")
+
+	// a connection logger takes precedence over the global one
+	buf.Reset()
+	var connBuf bytes.Buffer
+	logged := WithLogger(db, slog.New(slog.NewTextHandler(&connBuf, &slog.HandlerOptions{Level: slog.LevelDebug})), false)
+	assert.Equal(t, SQLite, GetDbType(logged))
+	type LogTest struct {
+		Id   int    `db:"ID" pk:"true"`
+		Name string `db:"NAME"`
+	}
+	var results []LogTest
+	err = QueryToStructs(logged, &results, "SELECT * FROM log_test WHERE NAME=?", "secret")
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Empty(t, buf.String())
+	assert.Contains(t, connBuf.String(), "secret")
+
+	// nothing is logged above the configured level
+	connBuf.Reset()
+	quiet := WithLogger(db, slog.New(slog.NewTextHandler(&connBuf, nil)), false)
+	for _, err := range QueryToMapsSeq(quiet, "SELECT * FROM log_test") {
+		assert.NoError(t, err)
+	}
+	assert.Empty(t, connBuf.String())
+	_, err = Exec(quiet, "INSERT INTO log_test (ID, NAME) VALUES (?, ?)", 1, "duplicate")
+	assert.Error(t, err)
+	assert.Equal(t, 1, strings.Count(connBuf.String(), "sql failed"))
+}
@@ -0,0 +1,141 @@
+package gosqlcrud
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Mapper maps the struct S to its columns without reflection. Mappers are generated by
+// cmd/gosqlcrud-mapper and registered with RegisterMapper in an init function. The functions
+// of this package use the mapper of a struct when there is one, and reflection otherwise.
+type Mapper[S any] struct {
+	// Columns are the db tags of the fields of S, in field order.
+	Columns []string
+	// Field returns what the column Columns[i] is scanned into: a pointer to the field of s,
+	// or ScanTarget of it for fields stored as JSON.
+	Field func(s *S, i int) any
+	// Values calls yield with the column, whether it's tagged pk:"true", and the value of every
+	// field of s, in field order. Nil pointer fields are skipped, fields stored as JSON are
+	// passed through DbValue.
+	Values func(s *S, yield func(column string, pk bool, value any))
+}
+
+// mapper is a Mapper with the struct type erased, so it can be used from functions whose
+// type parameter is a pointer to the struct.
+type mapper struct {
+	columns   []string
+	newStruct func() any
+	deref     func(p any) any
+	field     func(p any, i int) any
+	values    func(p any, yield func(column string, pk bool, value any))
+}
+
+var (
+	mappers      = map[reflect.Type]*mapper{}
+	mappersMutex = sync.RWMutex{}
+)
+
+// RegisterMapper - use m to map S from now on.
+func RegisterMapper[S any](m Mapper[S]) {
+	mappersMutex.Lock()
+	defer mappersMutex.Unlock()
+	mappers[reflect.TypeFor[S]()] = &mapper{
+		columns: m.Columns,
+		newStruct: func() any {
+			return new(S)
+		},
+		deref: func(p any) any {
+			return *p.(*S)
+		},
+		field: func(p any, i int) any {
+			return m.Field(p.(*S), i)
+		},
+		values: func(p any, yield func(column string, pk bool, value any)) {
+			m.Values(p.(*S), yield)
+		},
+	}
+}
+
+// mapperFor returns the registered mapper of structType, nil if there is none.
+func mapperFor(structType reflect.Type) *mapper {
+	mappersMutex.RLock()
+	defer mappersMutex.RUnlock()
+	return mappers[structType]
+}
+
+// columnIndexes returns the index in m.columns of every column of cols, -1 if the struct has
+// no field for it. Columns are matched case-insensitively.
+func (m *mapper) columnIndexes(cols []string) []int {
+	indexes := make([]int, len(cols))
+	for i, col := range cols {
+		indexes[i] = -1
+		for j, column := range m.columns {
+			if strings.EqualFold(col, column) {
+				indexes[i] = j
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+// scanTargets returns the scan targets of the columns at indexes for the struct p points to.
+func (m *mapper) scanTargets(p any, indexes []int) []any {
+	targets := make([]any, len(indexes))
+	for i, index := range indexes {
+		if index < 0 {
+			targets[i] = new(any)
+		} else {
+			targets[i] = m.field(p, index)
+		}
+	}
+	return targets
+}
+
+// ScanTarget - return what a column is scanned into for the field ptr points to: ptr itself
+// for primitive types and time.Time, otherwise a sql.Scanner decoding JSON into the field.
+// It's used by generated mappers.
+func ScanTarget(ptr any) any {
+	if isPrimitiveType(reflect.TypeOf(ptr).Elem()) {
+		return ptr
+	}
+	return &jsonScanner{ptr}
+}
+
+// DbValue - return the value stored for a field: v itself for primitive types and time.Time,
+// otherwise v marshalled to a JSON string. It's used by generated mappers.
+func DbValue(v any) any {
+	if v != nil && !isPrimitiveType(reflect.TypeOf(v)) {
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	return v
+}
+
+// jsonScanner decodes a JSON column into target. NULL and empty values set target to its zero
+// value, invalid JSON fails the scan.
+type jsonScanner struct {
+	target any
+}
+
+func (j *jsonScanner) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	case nil:
+	default:
+		data = fmt.Append(nil, v)
+	}
+	if len(data) == 0 {
+		reflect.ValueOf(j.target).Elem().SetZero()
+		return nil
+	}
+	return json.Unmarshal(data, j.target)
+}
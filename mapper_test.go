@@ -0,0 +1,109 @@
+package gosqlcrud
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+type mappedAddress struct {
+	City string `json:"city"`
+}
+
+type mappedUser struct {
+	Id      int            `db:"ID" pk:"true"`
+	Name    string         `db:"NAME"`
+	Email   *string        `db:"EMAIL"`
+	Address *mappedAddress `db:"ADDRESS"`
+}
+
+// mappedUserMapper is what gosqlcrud-mapper generates for mappedUser, with calls counted.
+func mappedUserMapper(fields *int, values *int) Mapper[mappedUser] {
+	return Mapper[mappedUser]{
+		Columns: []string{"ID", "NAME", "EMAIL", "ADDRESS"},
+		Field: func(s *mappedUser, i int) any {
+			*fields++
+			switch i {
+			case 0:
+				return &s.Id
+			case 1:
+				return &s.Name
+			case 2:
+				return &s.Email
+			case 3:
+				return ScanTarget(&s.Address)
+			}
+			return nil
+		},
+		Values: func(s *mappedUser, yield func(column string, pk bool, value any)) {
+			*values++
+			yield("ID", true, s.Id)
+			yield("NAME", false, s.Name)
+			if s.Email != nil {
+				yield("EMAIL", false, s.Email)
+			}
+			if s.Address != nil {
+				yield("ADDRESS", false, DbValue(s.Address))
+			}
+		},
+	}
+}
+
+func TestMapper(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = Exec(db, "CREATE TABLE mapped_user (ID INTEGER PRIMARY KEY, NAME TEXT, EMAIL TEXT, ADDRESS TEXT)")
+	assert.NoError(t, err)
+
+	email := "a@example.com"
+	user := mappedUser{Id: 1, Name: "A", Email: &email, Address: &mappedAddress{City: "Paris"}}
+	reflectedNonPk, reflectedPk := StructToDbMap(&user)
+	reflectedFields := StructFieldToDbField(&user)
+
+	var fields, values int
+	RegisterMapper(mappedUserMapper(&fields, &values))
+	defer func() {
+		mappersMutex.Lock()
+		clear(mappers)
+		mappersMutex.Unlock()
+	}()
+
+	// the mapper gives the same result as reflection
+	nonPk, pk := StructToDbMap(&user)
+	assert.Equal(t, reflectedNonPk, nonPk)
+	assert.Equal(t, reflectedPk, pk)
+	assert.Equal(t, reflectedFields, StructFieldToDbField(&user))
+	assert.Equal(t, 1, values)
+
+	_, err = Create(db, &user, "mapped_user")
+	assert.NoError(t, err)
+	_, err = Create(db, &mappedUser{Id: 2, Name: "B"}, "mapped_user")
+	assert.NoError(t, err)
+
+	var users []mappedUser
+	err = QueryToStructs(db, &users, "SELECT * FROM mapped_user ORDER BY ID")
+	assert.NoError(t, err)
+	assert.Equal(t, []mappedUser{user, {Id: 2, Name: "B"}}, users)
+	assert.Equal(t, 8, fields)
+
+	var userPtrs []*mappedUser
+	err = QueryToStructs(db, &userPtrs, "SELECT ID, NAME, 1 AS EXTRA FROM mapped_user ORDER BY ID")
+	assert.NoError(t, err)
+	assert.Equal(t, []*mappedUser{{Id: 1, Name: "A"}, {Id: 2, Name: "B"}}, userPtrs)
+
+	// NULL leaves non-pointer fields at their zero value
+	_, err = Exec(db, "INSERT INTO mapped_user (ID) VALUES (3)")
+	assert.NoError(t, err)
+	users = nil
+	err = QueryToStructs(db, &users, "SELECT * FROM mapped_user WHERE ID=3")
+	assert.NoError(t, err)
+	assert.Equal(t, []mappedUser{{Id: 3}}, users)
+
+	retrieved := mappedUser{Id: 1}
+	err = Retrieve(db, &retrieved, "mapped_user")
+	assert.NoError(t, err)
+	assert.Equal(t, user, retrieved)
+}
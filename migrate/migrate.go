@@ -0,0 +1,494 @@
+// Package migrate applies versioned SQL migrations with gosqlcrud.
+//
+// Migrations are pairs of files named <version>_<name>.up.sql and <version>_<name>.down.sql
+// in the root of an fs.FS, e.g. an embed.FS, where version is a positive integer. The down
+// file is only needed to roll the migration back. A file can hold several statements separated
+// by semicolons. Statements containing semicolons themselves, like procedure bodies, are
+// enclosed in lines "-- +gosqlcrud StatementBegin" and "-- +gosqlcrud StatementEnd".
+//
+// The applied versions are recorded in a bookkeeping table, schema_migrations by default, and
+// a row in a second table, schema_migrations_lock, keeps other processes from migrating at the
+// same time. The row records which process holds the lock since when. The lock of a process
+// killed while migrating is taken over once it's older than Options.StaleLockTimeout, or
+// released with ForceUnlock. Every migration runs in its own transaction, though MySQL and
+// Oracle commit DDL statements implicitly.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/elgs/gosqlcrud"
+)
+
+// Migration is a versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	hasDown bool
+}
+
+// Options configures a migration run. A nil *Options uses the defaults.
+type Options struct {
+	// Table is the bookkeeping table, "schema_migrations" by default. The lock table is
+	// named after it, with a "_lock" suffix.
+	Table string
+	// DryRun returns the migrations that would run, without running them or creating any table.
+	DryRun bool
+	// LockTimeout is how long to wait for another process to finish migrating, 1 minute by default.
+	LockTimeout time.Duration
+	// StaleLockTimeout is the age after which the lock of another process is considered stale
+	// and taken over, e.g. because the process was killed. It must exceed the time the longest
+	// migration takes, plus the clock skew between the hosts. 0 never takes a lock over.
+	StaleLockTimeout time.Duration
+}
+
+type appliedMigration struct {
+	Version   int64     `db:"VERSION" pk:"true"`
+	Name      string    `db:"NAME"`
+	AppliedAt time.Time `db:"APPLIED_AT"`
+}
+
+type migrationLock struct {
+	Id       int64  `db:"ID" pk:"true"`
+	Owner    string `db:"OWNER"`
+	LockedAt int64  `db:"LOCKED_AT"` // Unix seconds, which every driver scans alike
+}
+
+// owner identifies the lock rows of this process.
+var owner = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), rand.Text()[:8])
+}()
+
+const (
+	statementBegin = "-- +gosqlcrud StatementBegin"
+	statementEnd   = "-- +gosqlcrud StatementEnd"
+)
+
+// Load - read the migrations in the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+		base, up := strings.CutSuffix(fileName, ".up.sql")
+		if !up {
+			var down bool
+			base, down = strings.CutSuffix(fileName, ".down.sql")
+			if !down {
+				continue
+			}
+		}
+		versionString, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionString, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", fileName)
+		}
+		content, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, name)
+		}
+		if up {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+			m.hasDown = true
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Up - apply all migrations of fsys that are not applied yet. It returns the migrations in
+// the order they were applied, or would be with opts.DryRun.
+func Up(db gosqlcrud.TxBeginner, fsys fs.FS, opts *Options) ([]Migration, error) {
+	return UpContext(context.Background(), db, fsys, opts)
+}
+
+func UpContext(ctx context.Context, db gosqlcrud.TxBeginner, fsys fs.FS, opts *Options) ([]Migration, error) {
+	return migrate(ctx, db, fsys, opts, nil)
+}
+
+// To - migrate up or down to version: the migrations up to version are applied, the applied
+// ones after it are rolled back, latest first. To(db, fsys, 0, opts) rolls back everything.
+// It returns the migrations in the order they were run, or would be with opts.DryRun.
+func To(db gosqlcrud.TxBeginner, fsys fs.FS, version int64, opts *Options) ([]Migration, error) {
+	return ToContext(context.Background(), db, fsys, version, opts)
+}
+
+func ToContext(ctx context.Context, db gosqlcrud.TxBeginner, fsys fs.FS, version int64, opts *Options) ([]Migration, error) {
+	return migrate(ctx, db, fsys, opts, &version)
+}
+
+// Applied - return the applied versions in ascending order.
+func Applied(db gosqlcrud.TxBeginner, opts *Options) ([]int64, error) {
+	return AppliedContext(context.Background(), db, opts)
+}
+
+func AppliedContext(ctx context.Context, db gosqlcrud.TxBeginner, opts *Options) ([]int64, error) {
+	opts = withDefaults(opts)
+	exists, err := tableExists(ctx, db, opts.Table)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return appliedVersions(ctx, db, opts.Table)
+}
+
+func migrate(ctx context.Context, db gosqlcrud.TxBeginner, fsys fs.FS, opts *Options, target *int64) ([]Migration, error) {
+	opts = withDefaults(opts)
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if gosqlcrud.GetDbTypeContext(ctx, db) == gosqlcrud.Unknown {
+		return nil, errors.New("unknown database type")
+	}
+
+	if opts.DryRun {
+		applied, err := AppliedContext(ctx, db, opts)
+		if err != nil {
+			return nil, err
+		}
+		return plan(migrations, applied, target)
+	}
+
+	if err := createTables(ctx, db, opts.Table); err != nil {
+		return nil, err
+	}
+	if err := lock(ctx, db, opts); err != nil {
+		return nil, err
+	}
+	defer unlock(db, opts.Table, owner)
+
+	applied, err := appliedVersions(ctx, db, opts.Table)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := plan(migrations, applied, target)
+	if err != nil {
+		return nil, err
+	}
+	down := target != nil && len(applied) > 0 && applied[len(applied)-1] > *target
+	for i, m := range steps {
+		err := gosqlcrud.WithTxContext(ctx, db, nil, func(tx gosqlcrud.DBContext) error {
+			script := m.Up
+			if down {
+				script = m.Down
+			}
+			for _, statement := range splitStatements(script) {
+				if _, err := gosqlcrud.ExecContext(ctx, tx, statement); err != nil {
+					return err
+				}
+			}
+			record := &appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+			if down {
+				_, err := gosqlcrud.HardDeleteContext(ctx, tx, record, opts.Table)
+				return err
+			}
+			_, err := gosqlcrud.CreateContext(ctx, tx, record, opts.Table)
+			return err
+		})
+		if err != nil {
+			return steps[:i], fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return steps, nil
+}
+
+// plan returns the migrations to run to get from applied to target, nil target meaning the latest version.
+func plan(migrations []Migration, applied []int64, target *int64) ([]Migration, error) {
+	byVersion := map[int64]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+	if target != nil && len(applied) > 0 && applied[len(applied)-1] > *target {
+		var steps []Migration
+		for _, version := range slices.Backward(applied) {
+			if version <= *target {
+				break
+			}
+			m, ok := byVersion[version]
+			if !ok {
+				return nil, fmt.Errorf("applied migration %d not found", version)
+			}
+			if !m.hasDown {
+				return nil, fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+			steps = append(steps, m)
+		}
+		return steps, nil
+	}
+	var steps []Migration
+	for _, m := range migrations {
+		if target != nil && m.Version > *target {
+			break
+		}
+		if !slices.Contains(applied, m.Version) {
+			steps = append(steps, m)
+		}
+	}
+	return steps, nil
+}
+
+func withDefaults(opts *Options) *Options {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Table == "" {
+		o.Table = "schema_migrations"
+	}
+	if o.LockTimeout == 0 {
+		o.LockTimeout = time.Minute
+	}
+	gosqlcrud.SqlSafe(&o.Table)
+	return &o
+}
+
+func tableExists(ctx context.Context, db gosqlcrud.DBContext, table string) (bool, error) {
+	tables, err := gosqlcrud.GetAllTablesContext(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(tables, func(t string) bool {
+		return strings.EqualFold(t, table)
+	}), nil
+}
+
+// createTables creates the bookkeeping and lock tables if they don't exist.
+func createTables(ctx context.Context, db gosqlcrud.DBContext, table string) error {
+	bigint, varchar, timestamp := columnTypes(gosqlcrud.GetDbTypeContext(ctx, db))
+	statements := map[string]string{
+		table: fmt.Sprintf("CREATE TABLE %s (VERSION %s PRIMARY KEY, NAME %s, APPLIED_AT %s)",
+			table, bigint, varchar, timestamp),
+		table + "_lock": fmt.Sprintf("CREATE TABLE %s_lock (ID %s PRIMARY KEY, OWNER %s, LOCKED_AT %s)",
+			table, bigint, varchar, bigint),
+	}
+	for name, statement := range statements {
+		exists, err := tableExists(ctx, db, name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := gosqlcrud.ExecContext(ctx, db, statement); err != nil {
+			// another process may have created it in the meantime
+			if exists, _ := tableExists(ctx, db, name); !exists {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columnTypes returns the types of the bookkeeping columns for dbType.
+func columnTypes(dbType gosqlcrud.DbType) (bigint string, varchar string, timestamp string) {
+	switch dbType {
+	case gosqlcrud.MySQL:
+		return "BIGINT", "VARCHAR(255)", "DATETIME"
+	case gosqlcrud.SQLServer:
+		return "BIGINT", "NVARCHAR(255)", "DATETIME2"
+	case gosqlcrud.Oracle:
+		return "NUMBER(19)", "VARCHAR2(255)", "TIMESTAMP"
+	default:
+		return "BIGINT", "VARCHAR(255)", "TIMESTAMP"
+	}
+}
+
+// lock inserts the lock row, waiting while another process holds it, and taking it over once
+// it's stale.
+func lock(ctx context.Context, db gosqlcrud.DBContext, opts *Options) error {
+	lockTable := opts.Table + "_lock"
+	deadline := time.Now().Add(opts.LockTimeout)
+	for {
+		_, err := gosqlcrud.CreateContext(ctx, db, &migrationLock{Id: 1, Owner: owner, LockedAt: time.Now().Unix()}, lockTable)
+		if err == nil {
+			return nil
+		}
+		var constraintErr *gosqlcrud.ConstraintError
+		if !errors.As(err, &constraintErr) || constraintErr.Kind != gosqlcrud.UniqueViolation {
+			return err
+		}
+		holder := &migrationLock{Id: 1}
+		if err := gosqlcrud.RetrieveContext(ctx, db, holder, lockTable); err != nil {
+			if errors.Is(err, gosqlcrud.ErrNotFound) {
+				// released in the meantime
+				continue
+			}
+			return err
+		}
+		lockedAt := time.Unix(holder.LockedAt, 0).UTC()
+		if opts.StaleLockTimeout > 0 && time.Since(lockedAt) > opts.StaleLockTimeout {
+			// only the first of the processes taking over deletes the row
+			if err := unlockContext(ctx, db, opts.Table, holder.Owner); err != nil {
+				return err
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("migrations are locked by another process, %s since %s: %w",
+				holder.Owner, lockedAt.Format(time.RFC3339), err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func unlock(db gosqlcrud.DBContext, table string, lockOwner string) {
+	// the lock is released even if ctx is cancelled
+	unlockContext(context.Background(), db, table, lockOwner)
+}
+
+// unlockContext deletes the lock row if lockOwner holds it.
+func unlockContext(ctx context.Context, db gosqlcrud.DBContext, table string, lockOwner string) error {
+	dbType := gosqlcrud.GetDbTypeContext(ctx, db)
+	_, err := gosqlcrud.ExecContext(ctx, db, fmt.Sprintf("DELETE FROM %s_lock WHERE ID=%s AND OWNER=%s",
+		table, gosqlcrud.GetPlaceHolder(0, dbType), gosqlcrud.GetPlaceHolder(1, dbType)), 1, lockOwner)
+	return err
+}
+
+// ForceUnlock - release the migration lock whoever holds it, e.g. after the process holding it
+// was killed. Make sure no process is still migrating.
+func ForceUnlock(db gosqlcrud.DBContext, opts *Options) error {
+	return ForceUnlockContext(context.Background(), db, opts)
+}
+
+func ForceUnlockContext(ctx context.Context, db gosqlcrud.DBContext, opts *Options) error {
+	opts = withDefaults(opts)
+	exists, err := tableExists(ctx, db, opts.Table+"_lock")
+	if err != nil || !exists {
+		return err
+	}
+	_, err = gosqlcrud.HardDeleteContext(ctx, db, &migrationLock{Id: 1}, opts.Table+"_lock")
+	return err
+}
+
+func appliedVersions(ctx context.Context, db gosqlcrud.DBContext, table string) ([]int64, error) {
+	var applied []appliedMigration
+	err := gosqlcrud.QueryToStructsContext(ctx, db, &applied, fmt.Sprintf("SELECT VERSION FROM %s ORDER BY VERSION", table))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, len(applied))
+	for i, a := range applied {
+		versions[i] = a.Version
+	}
+	return versions, nil
+}
+
+// splitStatements splits script at the semicolons outside of quotes, comments and
+// StatementBegin/StatementEnd blocks. Statements consisting of comments only are dropped.
+func splitStatements(script string) []string {
+	var (
+		statements   []string
+		sb           strings.Builder
+		hasCode      bool
+		inBlock      bool
+		blockComment bool
+		quote        rune
+	)
+	flush := func() {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(sb.String()))
+		}
+		sb.Reset()
+		hasCode = false
+	}
+	for _, line := range strings.SplitAfter(script, "\n") {
+		switch strings.TrimSpace(line) {
+		case statementBegin:
+			flush()
+			inBlock = true
+			continue
+		case statementEnd:
+			flush()
+			inBlock = false
+			continue
+		}
+		if inBlock {
+			sb.WriteString(line)
+			hasCode = hasCode || strings.TrimSpace(line) != ""
+			continue
+		}
+		runes := []rune(line)
+	scan:
+		for i := 0; i < len(runes); i++ {
+			c := runes[i]
+			next := rune(0)
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			switch {
+			case blockComment:
+				if c == '*' && next == '/' {
+					blockComment = false
+					sb.WriteString("*/")
+					i++
+					continue
+				}
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '-' && next == '-':
+				sb.WriteString(string(runes[i:]))
+				break scan
+			case c == '/' && next == '*':
+				blockComment = true
+				sb.WriteString("/*")
+				i++
+				continue
+			case c == '\'' || c == '"':
+				quote = c
+				hasCode = true
+			case c == ';':
+				flush()
+				continue
+			case !unicode.IsSpace(c):
+				hasCode = true
+			}
+			sb.WriteRune(c)
+		}
+	}
+	flush()
+	return statements
+}
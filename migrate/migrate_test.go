@@ -0,0 +1,179 @@
+package migrate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/elgs/gosqlcrud"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+var migrations = fstest.MapFS{
+	"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (ID INTEGER PRIMARY KEY, NAME TEXT);\nINSERT INTO users (ID, NAME) VALUES (1, 'a;b');\n")},
+	"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"0002_add_email.up.sql":      {Data: []byte("-- emails are optional\nALTER TABLE users ADD COLUMN EMAIL TEXT;")},
+	"0002_add_email.down.sql":    {Data: []byte("ALTER TABLE users DROP COLUMN EMAIL;")},
+	"0003_create_trigger.up.sql": {Data: []byte(`-- +gosqlcrud StatementBegin
+CREATE TRIGGER users_name AFTER INSERT ON users
+BEGIN
+	UPDATE users SET NAME = upper(NEW.NAME) WHERE ID = NEW.ID;
+END;
+-- +gosqlcrud StatementEnd
+`)},
+	"0003_create_trigger.down.sql": {Data: []byte("DROP TRIGGER users_name;")},
+	"README.md":                    {Data: []byte("not a migration")},
+}
+
+func TestMigrate(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// a dry run changes nothing
+	planned, err := Up(db, migrations, &Options{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, versions(planned))
+	tables, err := gosqlcrud.GetAllTables(db)
+	assert.NoError(t, err)
+	assert.Empty(t, tables)
+
+	applied, err := To(db, migrations, 2, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, versions(applied))
+	applied, err = Up(db, migrations, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, versions(applied))
+	applied, err = Up(db, migrations, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	appliedVersions, err := Applied(db, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, appliedVersions)
+	_, err = gosqlcrud.Exec(db, "INSERT INTO users (ID, NAME, EMAIL) VALUES (2, 'c', 'c@example.com')")
+	assert.NoError(t, err)
+	users, err := gosqlcrud.QueryToMaps(db, "SELECT NAME FROM users ORDER BY ID")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"name": "a;b"}, {"name": "C"}}, users)
+
+	planned, err = To(db, migrations, 1, &Options{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, versions(planned))
+	rolledBack, err := To(db, migrations, 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, versions(rolledBack))
+	appliedVersions, err = Applied(db, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, appliedVersions)
+	columns, err := gosqlcrud.GetTableColumns(db, "users")
+	assert.NoError(t, err)
+	assert.Len(t, columns, 2)
+
+	rolledBack, err = To(db, migrations, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, versions(rolledBack))
+	tables, err = gosqlcrud.GetAllTables(db)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"schema_migrations", "schema_migrations_lock"}, tables)
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	broken := fstest.MapFS{
+		"1_ok.up.sql":     {Data: []byte("CREATE TABLE ok (ID INTEGER PRIMARY KEY);")},
+		"2_broken.up.sql": {Data: []byte("CREATE TABLE broken (ID INTEGER PRIMARY KEY); INSERT INTO missing VALUES (1);")},
+	}
+	applied, err := Up(db, broken, &Options{Table: "versions"})
+	assert.ErrorContains(t, err, "migration 2_broken")
+	assert.Equal(t, []int64{1}, versions(applied))
+	// the failed migration was rolled back as a whole
+	tables, err := gosqlcrud.GetAllTables(db)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ok", "versions", "versions_lock"}, tables)
+
+	// there is no down file to roll back
+	_, err = To(db, broken, 0, &Options{Table: "versions"})
+	assert.EqualError(t, err, "migration 1_ok has no down file")
+
+	_, err = Load(fstest.MapFS{"x_bad.up.sql": {Data: []byte("SELECT 1")}})
+	assert.EqualError(t, err, "invalid migration version in x_bad.up.sql")
+	_, err = Load(fstest.MapFS{"1_only.down.sql": {Data: []byte("SELECT 1")}})
+	assert.EqualError(t, err, "migration 1_only has no up file")
+}
+
+func TestMigrateLock(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = Up(db, fstest.MapFS{}, nil)
+	assert.NoError(t, err)
+	// another process is migrating
+	_, err = gosqlcrud.Create(db, &migrationLock{Id: 1, Owner: "other", LockedAt: time.Now().Unix()}, "schema_migrations_lock")
+	assert.NoError(t, err)
+	_, err = Up(db, migrations, &Options{LockTimeout: 200 * time.Millisecond, StaleLockTimeout: time.Hour})
+	assert.ErrorContains(t, err, "migrations are locked by another process, other since")
+
+	assert.NoError(t, ForceUnlock(db, nil))
+	applied, err := Up(db, migrations, nil)
+	assert.NoError(t, err)
+	assert.Len(t, applied, 3)
+
+	// another process was killed while migrating
+	_, err = gosqlcrud.Create(db, &migrationLock{Id: 1, Owner: "killed", LockedAt: time.Now().Add(-2 * time.Hour).Unix()}, "schema_migrations_lock")
+	assert.NoError(t, err)
+	_, err = To(db, migrations, 1, &Options{LockTimeout: 200 * time.Millisecond})
+	assert.ErrorContains(t, err, "migrations are locked by another process, killed since")
+	applied, err = To(db, migrations, 1, &Options{StaleLockTimeout: time.Hour})
+	assert.NoError(t, err)
+	assert.Len(t, applied, 2)
+	count, err := gosqlcrud.QueryToMaps(db, "SELECT COUNT(*) AS c FROM schema_migrations_lock")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count[0]["c"])
+
+	assert.NoError(t, ForceUnlock(db, &Options{Table: "missing"}))
+}
+
+func TestMigrateLockError(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// only a held lock is waited for, other errors are returned at once
+	_, err = gosqlcrud.Exec(db, "CREATE TABLE schema_migrations_lock (ID BIGINT PRIMARY KEY, OWNER TEXT, LOCKED_AT BIGINT, HOST TEXT NOT NULL)")
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = Up(db, migrations, nil)
+	var constraintErr *gosqlcrud.ConstraintError
+	assert.ErrorAs(t, err, &constraintErr)
+	assert.Equal(t, gosqlcrud.NotNullViolation, constraintErr.Kind)
+	assert.NotContains(t, err.Error(), "locked by another process")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements(`-- header
+CREATE TABLE a (ID INT); /* block; comment */ INSERT INTO a VALUES (1);
+INSERT INTO b VALUES ('it''s; fine', "x;y");
+-- trailing comment`)
+	assert.Equal(t, []string{
+		"-- header\nCREATE TABLE a (ID INT)",
+		"/* block; comment */ INSERT INTO a VALUES (1)",
+		`INSERT INTO b VALUES ('it''s; fine', "x;y")`,
+	}, statements)
+}
+
+func versions(migrations []Migration) []int64 {
+	result := []int64{}
+	for _, m := range migrations {
+		result = append(result, m.Version)
+	}
+	return result
+}
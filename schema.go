@@ -0,0 +1,396 @@
+package gosqlcrud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Table describes a table of the live database.
+type Table struct {
+	Name        string       `json:"name"`
+	Columns     []Column     `json:"columns"`
+	Indexes     []Index      `json:"indexes"`
+	ForeignKeys []ForeignKey `json:"foreign_keys"`
+}
+
+// Column describes a column of a table.
+type Column struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`     // declared type, as reported by the database
+	Nullable bool    `json:"nullable"` // primary key columns are never reported nullable
+	Default  *string `json:"default"`  // nil if the column has no default
+	// PrimaryKey is the 1-based position of the column in the primary key, 0 if it's not part of it.
+	PrimaryKey int `json:"primary_key"`
+	Position   int `json:"position"`
+}
+
+// Index describes a secondary index of a table. The primary key index is not included.
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+// ForeignKey describes a foreign key constraint of a table.
+type ForeignKey struct {
+	Name              string   `json:"name"` // empty on SQLite, where constraints are usually unnamed
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+}
+
+// schemaQueries holds the introspection queries of a database type. Every query takes
+// the table name as its only parameter, except tables, and returns the columns read below.
+type schemaQueries struct {
+	tables      string // table_name
+	columns     string // column_name, data_type, is_nullable, column_default, ordinal_position
+	primaryKeys string // column_name, ordinal_position
+	indexes     string // index_name, is_unique, column_name
+	foreignKeys string // constraint_name, column_name, referenced_table_name, referenced_column_name
+}
+
+var schemaQueriesMap = map[DbType]schemaQueries{
+	SQLite: {
+		tables:      `SELECT name AS table_name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`,
+		columns:     `SELECT name AS column_name, type AS data_type, CASE WHEN "notnull" = 1 THEN 'NO' ELSE 'YES' END AS is_nullable, dflt_value AS column_default, cid + 1 AS ordinal_position FROM pragma_table_info(?) ORDER BY cid`,
+		primaryKeys: `SELECT name AS column_name, pk AS ordinal_position FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk`,
+		indexes:     `SELECT il.name AS index_name, il."unique" AS is_unique, ii.name AS column_name FROM pragma_index_list(?) il JOIN pragma_index_info(il.name) ii WHERE il.origin <> 'pk' ORDER BY il.name, ii.seqno`,
+		// sqlite foreign keys are unnamed, fk_id tells them apart
+		foreignKeys: `SELECT '' AS constraint_name, id AS fk_id, "from" AS column_name, "table" AS referenced_table_name, "to" AS referenced_column_name FROM pragma_foreign_key_list(?) ORDER BY id, seq`,
+	},
+	MySQL: {
+		tables:      `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name`,
+		columns:     `SELECT column_name, column_type AS data_type, is_nullable, column_default, ordinal_position FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position`,
+		primaryKeys: `SELECT column_name, ordinal_position FROM information_schema.key_column_usage WHERE table_schema = DATABASE() AND table_name = ? AND constraint_name = 'PRIMARY' ORDER BY ordinal_position`,
+		indexes:     `SELECT index_name, CASE WHEN non_unique = 0 THEN 1 ELSE 0 END AS is_unique, column_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name <> 'PRIMARY' ORDER BY index_name, seq_in_index`,
+		foreignKeys: `SELECT constraint_name, column_name, referenced_table_name, referenced_column_name FROM information_schema.key_column_usage WHERE table_schema = DATABASE() AND table_name = ? AND referenced_table_name IS NOT NULL ORDER BY constraint_name, ordinal_position`,
+	},
+	PostgreSQL: {
+		tables: `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name`,
+		columns: `SELECT a.attname AS column_name, format_type(a.atttypid, a.atttypmod) AS data_type, CASE WHEN a.attnotnull THEN 'NO' ELSE 'YES' END AS is_nullable, pg_get_expr(d.adbin, d.adrelid) AS column_default, a.attnum AS ordinal_position
+			FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			WHERE c.relname = $1 AND n.nspname = current_schema() AND a.attnum > 0 AND NOT a.attisdropped
+			ORDER BY a.attnum`,
+		primaryKeys: `SELECT kcu.column_name, kcu.ordinal_position
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+			WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = current_schema() AND tc.table_name = $1
+			ORDER BY kcu.ordinal_position`,
+		indexes: `SELECT i.relname AS index_name, ix.indisunique AS is_unique, a.attname AS column_name
+			FROM pg_class t
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			JOIN pg_index ix ON ix.indrelid = t.oid
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord) ON true
+			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+			WHERE t.relname = $1 AND n.nspname = current_schema() AND NOT ix.indisprimary
+			ORDER BY i.relname, k.ord`,
+		foreignKeys: `SELECT kcu.constraint_name, kcu.column_name, rku.table_name AS referenced_table_name, rku.column_name AS referenced_column_name
+			FROM information_schema.referential_constraints rc
+			JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name
+			JOIN information_schema.key_column_usage rku ON rku.constraint_schema = rc.unique_constraint_schema AND rku.constraint_name = rc.unique_constraint_name AND rku.ordinal_position = kcu.position_in_unique_constraint
+			WHERE kcu.table_schema = current_schema() AND kcu.table_name = $1
+			ORDER BY kcu.constraint_name, kcu.ordinal_position`,
+	},
+	SQLServer: {
+		tables: `SELECT TABLE_NAME AS table_name FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = SCHEMA_NAME() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME`,
+		// DATA_TYPE has no length, precision or scale
+		columns: `SELECT COLUMN_NAME AS column_name, DATA_TYPE + CASE
+				WHEN CHARACTER_MAXIMUM_LENGTH = -1 THEN '(max)'
+				WHEN DATA_TYPE IN ('char', 'varchar', 'nchar', 'nvarchar', 'binary', 'varbinary') THEN '(' + CAST(CHARACTER_MAXIMUM_LENGTH AS VARCHAR(10)) + ')'
+				WHEN DATA_TYPE IN ('decimal', 'numeric') THEN '(' + CAST(NUMERIC_PRECISION AS VARCHAR(10)) + ',' + CAST(NUMERIC_SCALE AS VARCHAR(10)) + ')'
+				WHEN DATA_TYPE IN ('datetime2', 'datetimeoffset', 'time') THEN '(' + CAST(DATETIME_PRECISION AS VARCHAR(10)) + ')'
+				ELSE '' END AS data_type,
+			IS_NULLABLE AS is_nullable, COLUMN_DEFAULT AS column_default, ORDINAL_POSITION AS ordinal_position
+			FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = SCHEMA_NAME() AND TABLE_NAME = @p1 ORDER BY ORDINAL_POSITION`,
+		primaryKeys: `SELECT kcu.COLUMN_NAME AS column_name, kcu.ORDINAL_POSITION AS ordinal_position
+			FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
+			JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu ON kcu.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+			WHERE tc.CONSTRAINT_TYPE = 'PRIMARY KEY' AND tc.TABLE_SCHEMA = SCHEMA_NAME() AND tc.TABLE_NAME = @p1
+			ORDER BY kcu.ORDINAL_POSITION`,
+		indexes: `SELECT i.name AS index_name, i.is_unique, c.name AS column_name
+			FROM sys.indexes i
+			JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+			JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+			WHERE i.object_id = OBJECT_ID(@p1) AND i.is_primary_key = 0 AND ic.is_included_column = 0
+			ORDER BY i.name, ic.key_ordinal`,
+		foreignKeys: `SELECT fk.name AS constraint_name, pc.name AS column_name, rt.name AS referenced_table_name, rc.name AS referenced_column_name
+			FROM sys.foreign_keys fk
+			JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+			JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+			JOIN sys.tables rt ON rt.object_id = fkc.referenced_object_id
+			JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+			WHERE fk.parent_object_id = OBJECT_ID(@p1)
+			ORDER BY fk.name, fkc.constraint_column_id`,
+	},
+	Oracle: {
+		tables: `SELECT table_name FROM user_tables ORDER BY table_name`,
+		// data_type has no length, precision or scale, except for timestamps. INTEGER is NUMBER(*,0).
+		columns: `SELECT column_name, CASE
+				WHEN data_type = 'NUMBER' AND data_precision IS NOT NULL AND data_scale > 0 THEN 'NUMBER(' || data_precision || ',' || data_scale || ')'
+				WHEN data_type = 'NUMBER' AND data_precision IS NOT NULL THEN 'NUMBER(' || data_precision || ')'
+				WHEN data_type = 'NUMBER' AND data_scale IS NOT NULL THEN 'NUMBER(*,' || data_scale || ')'
+				WHEN data_type = 'FLOAT' THEN 'FLOAT(' || data_precision || ')'
+				WHEN data_type IN ('CHAR', 'VARCHAR2', 'NCHAR', 'NVARCHAR2') THEN data_type || '(' || char_length || ')'
+				WHEN data_type = 'RAW' THEN 'RAW(' || data_length || ')'
+				ELSE data_type END AS data_type,
+			CASE WHEN nullable = 'Y' THEN 'YES' ELSE 'NO' END AS is_nullable, data_default AS column_default, column_id AS ordinal_position
+			FROM user_tab_columns WHERE table_name = :1 ORDER BY column_id`,
+		primaryKeys: `SELECT cc.column_name, cc.position AS ordinal_position FROM user_constraints c JOIN user_cons_columns cc ON cc.constraint_name = c.constraint_name WHERE c.table_name = :1 AND c.constraint_type = 'P' ORDER BY cc.position`,
+		indexes: `SELECT i.index_name, CASE WHEN i.uniqueness = 'UNIQUE' THEN 1 ELSE 0 END AS is_unique, ic.column_name
+			FROM user_indexes i
+			JOIN user_ind_columns ic ON ic.index_name = i.index_name
+			WHERE i.table_name = :1 AND NOT EXISTS (SELECT 1 FROM user_constraints c WHERE c.index_name = i.index_name AND c.constraint_type = 'P')
+			ORDER BY i.index_name, ic.column_position`,
+		foreignKeys: `SELECT c.constraint_name, cc.column_name, rc.table_name AS referenced_table_name, rcc.column_name AS referenced_column_name
+			FROM user_constraints c
+			JOIN user_cons_columns cc ON cc.constraint_name = c.constraint_name
+			JOIN user_constraints rc ON rc.constraint_name = c.r_constraint_name
+			JOIN user_cons_columns rcc ON rcc.constraint_name = rc.constraint_name AND rcc.position = cc.position
+			WHERE c.table_name = :1 AND c.constraint_type = 'R'
+			ORDER BY c.constraint_name, cc.position`,
+	},
+}
+
+func getSchemaQueries(ctx context.Context, conn DBContext) (schemaQueries, error) {
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return schemaQueries{}, errors.New("unknown database type")
+	}
+	queries, ok := schemaQueriesMap[dbType]
+	if !ok {
+		return schemaQueries{}, fmt.Errorf("schema introspection is not supported for database type %v", dbType)
+	}
+	return queries, nil
+}
+
+// GetAllTables - return the names of all tables in the current database or schema
+func GetAllTables[T DB](conn T) ([]string, error) {
+	return GetAllTablesContext(context.Background(), toDBContext(conn))
+}
+
+func GetAllTablesContext[T DBContext](ctx context.Context, conn T) ([]string, error) {
+	queries, err := getSchemaQueries(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := QueryToMapsContext(ctx, conn, queries.tables)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(rows))
+	for _, row := range rows {
+		tables = append(tables, schemaString(row["table_name"]))
+	}
+	return tables, nil
+}
+
+// GetTableColumns - return the columns of a table in their declared order
+func GetTableColumns[T DB](conn T, tableName string) ([]Column, error) {
+	return GetTableColumnsContext(context.Background(), toDBContext(conn), tableName)
+}
+
+func GetTableColumnsContext[T DBContext](ctx context.Context, conn T, tableName string) ([]Column, error) {
+	queries, err := getSchemaQueries(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := QueryToMapsContext(ctx, conn, queries.columns, tableName)
+	if err != nil {
+		return nil, err
+	}
+	pks, err := GetTablePrimaryKeysContext(ctx, conn, tableName)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]Column, 0, len(rows))
+	for _, row := range rows {
+		column := Column{
+			Name:     schemaString(row["column_name"]),
+			Type:     schemaString(row["data_type"]),
+			Nullable: strings.EqualFold(schemaString(row["is_nullable"]), "YES"),
+			Position: schemaInt(row["ordinal_position"]),
+		}
+		if row["column_default"] != nil {
+			def := schemaString(row["column_default"])
+			column.Default = &def
+		}
+		for i, pk := range pks {
+			if strings.EqualFold(pk, column.Name) {
+				column.PrimaryKey = i + 1
+				column.Nullable = false
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// GetTablePrimaryKeys - return the primary key columns of a table in key order
+func GetTablePrimaryKeys[T DB](conn T, tableName string) ([]string, error) {
+	return GetTablePrimaryKeysContext(context.Background(), toDBContext(conn), tableName)
+}
+
+func GetTablePrimaryKeysContext[T DBContext](ctx context.Context, conn T, tableName string) ([]string, error) {
+	queries, err := getSchemaQueries(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := QueryToMapsContext(ctx, conn, queries.primaryKeys, tableName)
+	if err != nil {
+		return nil, err
+	}
+	pks := make([]string, 0, len(rows))
+	for _, row := range rows {
+		pks = append(pks, schemaString(row["column_name"]))
+	}
+	return pks, nil
+}
+
+// GetTableIndexes - return the secondary indexes of a table
+func GetTableIndexes[T DB](conn T, tableName string) ([]Index, error) {
+	return GetTableIndexesContext(context.Background(), toDBContext(conn), tableName)
+}
+
+func GetTableIndexesContext[T DBContext](ctx context.Context, conn T, tableName string) ([]Index, error) {
+	queries, err := getSchemaQueries(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := QueryToMapsContext(ctx, conn, queries.indexes, tableName)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []Index{}
+	for _, row := range rows {
+		name := schemaString(row["index_name"])
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != name {
+			indexes = append(indexes, Index{
+				Name:   name,
+				Unique: schemaBool(row["is_unique"]),
+			})
+		}
+		last := &indexes[len(indexes)-1]
+		last.Columns = append(last.Columns, schemaString(row["column_name"]))
+	}
+	return indexes, nil
+}
+
+// GetTableForeignKeys - return the foreign keys of a table
+func GetTableForeignKeys[T DB](conn T, tableName string) ([]ForeignKey, error) {
+	return GetTableForeignKeysContext(context.Background(), toDBContext(conn), tableName)
+}
+
+func GetTableForeignKeysContext[T DBContext](ctx context.Context, conn T, tableName string) ([]ForeignKey, error) {
+	queries, err := getSchemaQueries(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := QueryToMapsContext(ctx, conn, queries.foreignKeys, tableName)
+	if err != nil {
+		return nil, err
+	}
+	foreignKeys := []ForeignKey{}
+	lastKey := ""
+	for i, row := range rows {
+		key := schemaString(row["constraint_name"])
+		if fkId, ok := row["fk_id"]; ok {
+			key = schemaString(fkId)
+		}
+		if i == 0 || key != lastKey {
+			foreignKeys = append(foreignKeys, ForeignKey{
+				Name:            schemaString(row["constraint_name"]),
+				ReferencedTable: schemaString(row["referenced_table_name"]),
+			})
+			lastKey = key
+		}
+		last := &foreignKeys[len(foreignKeys)-1]
+		last.Columns = append(last.Columns, schemaString(row["column_name"]))
+		last.ReferencedColumns = append(last.ReferencedColumns, schemaString(row["referenced_column_name"]))
+	}
+	// sqlite leaves the referenced columns empty when the primary key is referenced implicitly
+	for i, fk := range foreignKeys {
+		if fk.ReferencedColumns[0] != "" {
+			continue
+		}
+		pks, err := GetTablePrimaryKeysContext(ctx, conn, fk.ReferencedTable)
+		if err != nil {
+			return nil, err
+		}
+		if len(pks) == len(fk.Columns) {
+			foreignKeys[i].ReferencedColumns = pks
+		}
+	}
+	return foreignKeys, nil
+}
+
+// GetTable - return the full description of a table, or an error if the table doesn't exist
+func GetTable[T DB](conn T, tableName string) (*Table, error) {
+	return GetTableContext(context.Background(), toDBContext(conn), tableName)
+}
+
+func GetTableContext[T DBContext](ctx context.Context, conn T, tableName string) (*Table, error) {
+	columns, err := GetTableColumnsContext(ctx, conn, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+	indexes, err := GetTableIndexesContext(ctx, conn, tableName)
+	if err != nil {
+		return nil, err
+	}
+	foreignKeys, err := GetTableForeignKeysContext(ctx, conn, tableName)
+	if err != nil {
+		return nil, err
+	}
+	return &Table{
+		Name:        tableName,
+		Columns:     columns,
+		Indexes:     indexes,
+		ForeignKeys: foreignKeys,
+	}, nil
+}
+
+func schemaString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case *any:
+		return schemaString(*v)
+	}
+	return fmt.Sprint(v)
+}
+
+func schemaInt(v any) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	i, _ := strconv.Atoi(schemaString(v))
+	return i
+}
+
+func schemaBool(v any) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return schemaInt(v) != 0
+}
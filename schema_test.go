@@ -0,0 +1,56 @@
+package gosqlcrud
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestSchema(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = Exec(db, "CREATE TABLE users (ID INTEGER PRIMARY KEY, EMAIL VARCHAR(255) NOT NULL, NAME TEXT DEFAULT 'nobody')")
+	assert.NoError(t, err)
+	_, err = Exec(db, "CREATE UNIQUE INDEX users_email ON users (EMAIL)")
+	assert.NoError(t, err)
+	_, err = Exec(db, "CREATE TABLE posts (USER_ID INTEGER NOT NULL REFERENCES users, SEQ INTEGER NOT NULL, TITLE TEXT, PRIMARY KEY (USER_ID, SEQ))")
+	assert.NoError(t, err)
+	_, err = Exec(db, "CREATE INDEX posts_title ON posts (TITLE, SEQ)")
+	assert.NoError(t, err)
+
+	tables, err := GetAllTables(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"posts", "users"}, tables)
+
+	pks, err := GetTablePrimaryKeys(db, "posts")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"USER_ID", "SEQ"}, pks)
+
+	users, err := GetTable(db, "users")
+	assert.NoError(t, err)
+	assert.Len(t, users.Columns, 3)
+	assert.Equal(t, Column{Name: "ID", Type: "INTEGER", Nullable: false, PrimaryKey: 1, Position: 1}, users.Columns[0])
+	assert.Equal(t, "VARCHAR(255)", users.Columns[1].Type)
+	assert.False(t, users.Columns[1].Nullable)
+	assert.Nil(t, users.Columns[1].Default)
+	assert.True(t, users.Columns[2].Nullable)
+	assert.Equal(t, "'nobody'", *users.Columns[2].Default)
+	assert.Equal(t, []Index{{Name: "users_email", Columns: []string{"EMAIL"}, Unique: true}}, users.Indexes)
+	assert.Empty(t, users.ForeignKeys)
+
+	posts, err := GetTable(db, "posts")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, posts.Columns[0].PrimaryKey)
+	assert.Equal(t, 2, posts.Columns[1].PrimaryKey)
+	assert.Equal(t, 0, posts.Columns[2].PrimaryKey)
+	assert.Equal(t, []Index{{Name: "posts_title", Columns: []string{"TITLE", "SEQ"}, Unique: false}}, posts.Indexes)
+	assert.Equal(t, []ForeignKey{{Columns: []string{"USER_ID"}, ReferencedTable: "users", ReferencedColumns: []string{"ID"}}}, posts.ForeignKeys)
+
+	_, err = GetTable(db, "missing")
+	assert.Error(t, err)
+}
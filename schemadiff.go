@@ -0,0 +1,334 @@
+package gosqlcrud
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaIssueKind is the kind of a difference between a model struct and its table.
+type SchemaIssueKind int
+
+const (
+	// MissingTable - the table of the model doesn't exist.
+	MissingTable SchemaIssueKind = iota + 1
+	// MissingColumn - a db tag of the model has no column in the table.
+	MissingColumn
+	// ExtraNotNullColumn - a NOT NULL column without default has no field in the model, so Create fails.
+	ExtraNotNullColumn
+	// TypeMismatch - the type of a column can't hold the values of its field.
+	TypeMismatch
+	// MissingPrimaryKey - the pk fields of the model are not the primary key of the table.
+	MissingPrimaryKey
+)
+
+func (k SchemaIssueKind) String() string {
+	switch k {
+	case MissingTable:
+		return "missing table"
+	case MissingColumn:
+		return "missing column"
+	case ExtraNotNullColumn:
+		return "extra not null column"
+	case TypeMismatch:
+		return "type mismatch"
+	case MissingPrimaryKey:
+		return "missing primary key"
+	}
+	return "unknown"
+}
+
+// SchemaIssue is a difference between a model struct and its table in the database.
+type SchemaIssue struct {
+	Kind    SchemaIssueKind
+	Table   string
+	Column  string // empty for MissingTable and MissingPrimaryKey
+	Message string
+	// Sql holds the statements reconciling the table with the model, empty if they can't be
+	// generated for the database, e.g. SQLite can't alter columns.
+	Sql []string
+}
+
+type model struct {
+	structType reflect.Type
+	table      string
+}
+
+var (
+	models      []model
+	modelsMutex = sync.RWMutex{}
+)
+
+// RegisterModel - register the struct S as the model of table, to be checked by CheckSchema.
+func RegisterModel[S any](table string) {
+	modelsMutex.Lock()
+	defer modelsMutex.Unlock()
+	models = append(models, model{
+		structType: reflect.TypeOf((*S)(nil)).Elem(),
+		table:      table,
+	})
+}
+
+// CheckSchema - compare all registered models with their tables in the database, and return
+// the differences in registration order.
+func CheckSchema[T DB](conn T) ([]SchemaIssue, error) {
+	return CheckSchemaContext(context.Background(), toDBContext(conn))
+}
+
+func CheckSchemaContext[T DBContext](ctx context.Context, conn T) ([]SchemaIssue, error) {
+	modelsMutex.RLock()
+	registered := slices.Clone(models)
+	modelsMutex.RUnlock()
+
+	issues := []SchemaIssue{}
+	for _, m := range registered {
+		modelIssues, err := checkModel(ctx, conn, m.structType, m.table)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, modelIssues...)
+	}
+	return issues, nil
+}
+
+// CheckModel - compare the struct S with table in the database, S doesn't need to be registered.
+func CheckModel[S any, T DB](conn T, table string) ([]SchemaIssue, error) {
+	return CheckModelContext[S](context.Background(), toDBContext(conn), table)
+}
+
+func CheckModelContext[S any, T DBContext](ctx context.Context, conn T, table string) ([]SchemaIssue, error) {
+	return checkModel(ctx, conn, reflect.TypeOf((*S)(nil)).Elem(), table)
+}
+
+// SchemaSql - return the statements of all issues, in order.
+func SchemaSql(issues []SchemaIssue) []string {
+	statements := []string{}
+	for _, issue := range issues {
+		statements = append(statements, issue.Sql...)
+	}
+	return statements
+}
+
+func checkModel(ctx context.Context, conn DBContext, structType reflect.Type, table string) ([]SchemaIssue, error) {
+	dbType := GetDbTypeContext(ctx, conn)
+	columns, err := GetTableColumnsContext(ctx, conn, table)
+	if err != nil {
+		return nil, err
+	}
+	SqlSafe(&table)
+	if len(columns) == 0 {
+		statements, _ := createTableSQL(dbType, structType, table)
+		return []SchemaIssue{{
+			Kind:    MissingTable,
+			Table:   table,
+			Message: fmt.Sprintf("table %s doesn't exist", table),
+			Sql:     statements,
+		}}, nil
+	}
+
+	dialect, _ := dbType.Dialect().(DDLDialect)
+	issues := []SchemaIssue{}
+	matched := map[string]bool{}
+	var modelPks, tablePks []string
+	for _, column := range columns {
+		if column.PrimaryKey > 0 {
+			tablePks = append(tablePks, strings.ToLower(column.Name))
+		}
+	}
+	for _, field := range dbFields(structType) {
+		name := field.Tag.Get("db")
+		if field.Tag.Get("pk") == "true" {
+			modelPks = append(modelPks, strings.ToLower(name))
+		}
+		i := slices.IndexFunc(columns, func(c Column) bool {
+			return strings.EqualFold(c.Name, name)
+		})
+		if i < 0 {
+			issues = append(issues, SchemaIssue{
+				Kind:    MissingColumn,
+				Table:   table,
+				Column:  name,
+				Message: fmt.Sprintf("column %s.%s of field %s doesn't exist", table, name, field.Name),
+				Sql:     addColumnSql(dbType, dialect, table, field),
+			})
+			continue
+		}
+		column := columns[i]
+		matched[strings.ToLower(column.Name)] = true
+		if !columnFitsField(column.Type, field.Type) {
+			issues = append(issues, SchemaIssue{
+				Kind:    TypeMismatch,
+				Table:   table,
+				Column:  column.Name,
+				Message: fmt.Sprintf("column %s.%s of type %s can't hold field %s of type %s", table, column.Name, column.Type, field.Name, field.Type),
+				Sql:     alterColumnTypeSql(dbType, dialect, table, field, column),
+			})
+		}
+	}
+	for _, column := range columns {
+		if matched[strings.ToLower(column.Name)] || column.Nullable || column.Default != nil || column.PrimaryKey > 0 {
+			continue
+		}
+		issues = append(issues, SchemaIssue{
+			Kind:    ExtraNotNullColumn,
+			Table:   table,
+			Column:  column.Name,
+			Message: fmt.Sprintf("column %s.%s is NOT NULL without default, but has no field", table, column.Name),
+			Sql:     dropNotNullSql(dbType, table, column),
+		})
+	}
+	slices.Sort(modelPks)
+	slices.Sort(tablePks)
+	if len(modelPks) == 0 || !slices.Equal(modelPks, tablePks) {
+		issue := SchemaIssue{
+			Kind:    MissingPrimaryKey,
+			Table:   table,
+			Message: fmt.Sprintf("primary key of %s is (%s), the pk fields are (%s)", table, strings.Join(tablePks, ", "), strings.Join(modelPks, ", ")),
+		}
+		if len(tablePks) == 0 && len(modelPks) > 0 && dbType != SQLite {
+			var keys []string
+			for _, field := range dbFields(structType) {
+				if field.Tag.Get("pk") == "true" {
+					keys = append(keys, field.Tag.Get("db"))
+				}
+			}
+			pkKeys := strings.Join(keys, ", ")
+			SqlSafe(&pkKeys)
+			issue.Sql = []string{fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s)", table, pkKeys)}
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+func addColumnSql(dbType DbType, dialect DDLDialect, table string, field reflect.StructField) []string {
+	if dialect == nil {
+		return nil
+	}
+	column, columnType, err := fieldColumnType(dialect, field, false)
+	if err != nil {
+		return nil
+	}
+	definition := column + " " + columnType + columnConstraints(field, false)
+	switch dbType {
+	case SQLServer:
+		return []string{fmt.Sprintf("ALTER TABLE %s ADD %s", table, definition)}
+	case Oracle:
+		return []string{fmt.Sprintf("ALTER TABLE %s ADD (%s)", table, definition)}
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, definition)}
+}
+
+func alterColumnTypeSql(dbType DbType, dialect DDLDialect, table string, field reflect.StructField, column Column) []string {
+	if dialect == nil {
+		return nil
+	}
+	_, columnType, err := fieldColumnType(dialect, field, false)
+	if err != nil {
+		return nil
+	}
+	name := column.Name
+	SqlSafe(&name)
+	null := " NULL"
+	if !column.Nullable {
+		null = " NOT NULL"
+	}
+	switch dbType {
+	case PostgreSQL:
+		return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", table, name, columnType)}
+	case MySQL:
+		return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s%s", table, name, columnType, null)}
+	case SQLServer:
+		return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s%s", table, name, columnType, null)}
+	case Oracle:
+		return []string{fmt.Sprintf("ALTER TABLE %s MODIFY (%s %s)", table, name, columnType)}
+	}
+	return nil
+}
+
+func dropNotNullSql(dbType DbType, table string, column Column) []string {
+	name := column.Name
+	SqlSafe(&name)
+	switch dbType {
+	case PostgreSQL:
+		return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", table, name)}
+	case MySQL:
+		return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s NULL", table, name, column.Type)}
+	case SQLServer:
+		return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s NULL", table, name, column.Type)}
+	case Oracle:
+		return []string{fmt.Sprintf("ALTER TABLE %s MODIFY (%s NULL)", table, name)}
+	}
+	return nil
+}
+
+// columnCategory returns the kind of values a column of the database type columnType holds,
+// "" if unknown.
+func columnCategory(columnType string) string {
+	t := strings.ToLower(columnType)
+	if i := strings.Index(t, "("); i >= 0 {
+		t = t[:i]
+	}
+	t = strings.TrimSpace(t)
+	contains := func(parts ...string) bool {
+		return slices.ContainsFunc(parts, func(part string) bool {
+			return strings.Contains(t, part)
+		})
+	}
+	switch {
+	case t == "":
+		return ""
+	case contains("char", "text", "clob", "string", "uuid", "uniqueidentifier", "enum"):
+		return "string"
+	case contains("json"):
+		return "json"
+	case contains("bool") || t == "bit":
+		return "bool"
+	case contains("interval", "point"):
+		return ""
+	case contains("int", "serial"):
+		return "int"
+	case contains("float", "double", "real"):
+		return "float"
+	case contains("numeric", "decimal", "number", "money"):
+		return "numeric"
+	case contains("date", "time"):
+		return "time"
+	case contains("blob", "binary", "bytea", "image"):
+		return "binary"
+	}
+	return ""
+}
+
+// columnFitsField reports whether a column of the database type columnType can hold the
+// values of a field of type fieldType. Unknown column types always fit.
+func columnFitsField(columnType string, fieldType reflect.Type) bool {
+	category := columnCategory(columnType)
+	if category == "" {
+		return true
+	}
+	var fits []string
+	fieldType = derefType(fieldType)
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		fits = []string{"bool", "int", "numeric"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fits = []string{"int", "numeric"}
+	case reflect.Float32, reflect.Float64:
+		fits = []string{"float", "numeric"}
+	case reflect.String:
+		fits = []string{"string", "json"}
+	default:
+		if fieldType == reflect.TypeOf(time.Time{}) {
+			fits = []string{"time"}
+		} else {
+			fits = []string{"json", "string", "binary"}
+		}
+	}
+	return slices.Contains(fits, category)
+}
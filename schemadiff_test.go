@@ -0,0 +1,119 @@
+package gosqlcrud
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+type diffUser struct {
+	Id        int        `db:"ID" pk:"true"`
+	Name      string     `db:"NAME"`
+	Email     *string    `db:"EMAIL" size:"100"`
+	Age       int        `db:"AGE"`
+	CreatedAt *time.Time `db:"CREATED_AT"`
+}
+
+func TestCheckSchema(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = Exec(db, "CREATE TABLE diff_user (ID INTEGER PRIMARY KEY, NAME TEXT NOT NULL, AGE TEXT, CREATED_AT DATETIME, TENANT TEXT NOT NULL, NOTE TEXT NOT NULL DEFAULT '')")
+	assert.NoError(t, err)
+	_, err = Exec(db, "CREATE TABLE diff_log (ID INTEGER, MESSAGE TEXT)")
+	assert.NoError(t, err)
+
+	issues, err := CheckModel[diffUser](db, "diff_user")
+	assert.NoError(t, err)
+	assert.Equal(t, []SchemaIssue{{
+		Kind:    MissingColumn,
+		Table:   "diff_user",
+		Column:  "EMAIL",
+		Message: "column diff_user.EMAIL of field Email doesn't exist",
+		Sql:     []string{"ALTER TABLE diff_user ADD COLUMN EMAIL VARCHAR(100)"},
+	}, {
+		Kind:    TypeMismatch,
+		Table:   "diff_user",
+		Column:  "AGE",
+		Message: "column diff_user.AGE of type TEXT can't hold field Age of type int",
+	}, {
+		Kind:    ExtraNotNullColumn,
+		Table:   "diff_user",
+		Column:  "TENANT",
+		Message: "column diff_user.TENANT is NOT NULL without default, but has no field",
+	}}, issues)
+
+	type diffLog struct {
+		Id      int64  `db:"ID" pk:"true"`
+		Message string `db:"MESSAGE"`
+	}
+	type diffMissing struct {
+		Id int64 `db:"ID" pk:"true"`
+	}
+	RegisterModel[diffLog]("diff_log")
+	RegisterModel[diffMissing]("diff_missing")
+	defer func() {
+		models = nil
+	}()
+	issues, err = CheckSchema(db)
+	assert.NoError(t, err)
+	assert.Len(t, issues, 2)
+	assert.Equal(t, MissingPrimaryKey, issues[0].Kind)
+	assert.Equal(t, "primary key of diff_log is (), the pk fields are (id)", issues[0].Message)
+	assert.Empty(t, issues[0].Sql)
+	assert.Equal(t, MissingTable, issues[1].Kind)
+
+	// the missing table can be created from its statements
+	for _, statement := range SchemaSql(issues) {
+		_, err := Exec(db, statement)
+		assert.NoError(t, err)
+	}
+	issues, err = CheckModel[diffMissing](db, "diff_missing")
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestSchemaDiffSql(t *testing.T) {
+	emailField, _ := reflect.TypeOf(diffUser{}).FieldByName("Email")
+	ageField, _ := reflect.TypeOf(diffUser{}).FieldByName("Age")
+	age := Column{Name: "AGE", Type: "text", Nullable: false}
+
+	assert.Equal(t, []string{"ALTER TABLE users ADD COLUMN EMAIL VARCHAR(100)"}, addColumnSql(PostgreSQL, postgresDialect{}, "users", emailField))
+	assert.Equal(t, []string{"ALTER TABLE users ADD EMAIL NVARCHAR(100)"}, addColumnSql(SQLServer, sqlServerDialect{}, "users", emailField))
+	assert.Equal(t, []string{"ALTER TABLE users ADD (EMAIL VARCHAR2(100))"}, addColumnSql(Oracle, oracleDialect{}, "users", emailField))
+
+	assert.Equal(t, []string{"ALTER TABLE users ALTER COLUMN AGE TYPE BIGINT"}, alterColumnTypeSql(PostgreSQL, postgresDialect{}, "users", ageField, age))
+	assert.Equal(t, []string{"ALTER TABLE users MODIFY COLUMN AGE BIGINT NOT NULL"}, alterColumnTypeSql(MySQL, mysqlDialect{}, "users", ageField, age))
+	assert.Nil(t, alterColumnTypeSql(SQLite, sqliteDialect{}, "users", ageField, age))
+
+	assert.Equal(t, []string{"ALTER TABLE users ALTER COLUMN AGE DROP NOT NULL"}, dropNotNullSql(PostgreSQL, "users", age))
+	assert.Equal(t, []string{"ALTER TABLE users MODIFY COLUMN AGE text NULL"}, dropNotNullSql(MySQL, "users", age))
+	assert.Equal(t, []string{"ALTER TABLE users ALTER COLUMN AGE nvarchar(100) NULL"}, dropNotNullSql(SQLServer, "users", Column{Name: "AGE", Type: "nvarchar(100)"}))
+	assert.Equal(t, []string{"ALTER TABLE users ALTER COLUMN AGE int NULL"}, dropNotNullSql(SQLServer, "users", Column{Name: "AGE", Type: "int"}))
+}
+
+func TestColumnFitsField(t *testing.T) {
+	for columnType, field := range map[string]any{
+		"INTEGER":                     int64(0),
+		"tinyint(1)":                  false,
+		"NUMBER(19)":                  0,
+		"character varying(255)":      "",
+		"timestamp with time zone":    time.Time{},
+		"DATETIME2":                   &time.Time{},
+		"jsonb":                       map[string]any{},
+		"double precision":            0.0,
+		"uniqueidentifier":            "",
+		"":                            0,
+		"USER_DEFINED_TYPE_WE_IGNORE": 0,
+	} {
+		assert.True(t, columnFitsField(columnType, reflect.TypeOf(field)), columnType)
+	}
+	assert.False(t, columnFitsField("varchar(10)", reflect.TypeOf(0)))
+	assert.False(t, columnFitsField("bigint", reflect.TypeOf("")))
+	assert.False(t, columnFitsField("timestamp", reflect.TypeOf(0.0)))
+}
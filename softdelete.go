@@ -0,0 +1,215 @@
+package gosqlcrud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type includeDeletedKey struct{}
+
+// IncludeDeleted returns a context that makes RetrieveContext find rows marked as deleted,
+// e.g. for an admin "show deleted" view.
+func IncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+func isIncludeDeleted(ctx context.Context) bool {
+	v, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return v
+}
+
+// softDeleteColumn is a field tagged softdelete:"true". A *time.Time field holds the deletion
+// time and is nil while the row is not deleted, a bool or *bool field is true once deleted.
+type softDeleteColumn struct {
+	key    string
+	field  reflect.Value
+	isTime bool
+}
+
+var timePtrType = reflect.TypeOf((*time.Time)(nil))
+
+func softDeleteField[T any](s *T) (softDeleteColumn, bool, error) {
+	key, field, ok := taggedField(s, "softdelete")
+	if !ok {
+		return softDeleteColumn{}, false, nil
+	}
+	SqlSafe(&key)
+	switch {
+	case field.Type() == timePtrType:
+		return softDeleteColumn{key: key, field: field, isTime: true}, true, nil
+	case field.Kind() == reflect.Bool, field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Bool:
+		return softDeleteColumn{key: key, field: field}, true, nil
+	}
+	return softDeleteColumn{}, false, fmt.Errorf("softdelete field %s must be *time.Time, bool or *bool", key)
+}
+
+// notDeletedClause returns the condition matching rows that are not deleted, index is the
+// index of its first placeholder.
+func (c softDeleteColumn) notDeletedClause(index int, dbType DbType) (string, []any) {
+	if c.isTime {
+		return fmt.Sprintf("AND %s IS NULL", c.key), nil
+	}
+	return fmt.Sprintf("AND (%s IS NULL OR %s=%s)", c.key, c.key, GetPlaceHolder(index, dbType)), []any{false}
+}
+
+// deletedClause returns the condition matching rows that are deleted.
+func (c softDeleteColumn) deletedClause(index int, dbType DbType) (string, []any) {
+	if c.isTime {
+		return fmt.Sprintf("AND %s IS NOT NULL", c.key), nil
+	}
+	return fmt.Sprintf("AND %s=%s", c.key, GetPlaceHolder(index, dbType)), []any{true}
+}
+
+// set stores the deleted state in the struct field and returns the value to store in the column.
+func (c softDeleteColumn) set(deleted bool) any {
+	var value any
+	switch {
+	case c.isTime && deleted:
+		now := time.Now()
+		value = now
+		c.field.Set(reflect.ValueOf(&now))
+	case c.isTime:
+		value = nil
+		c.field.Set(reflect.Zero(c.field.Type()))
+	case c.field.Kind() == reflect.Pointer:
+		value = deleted
+		c.field.Set(reflect.ValueOf(&deleted))
+	default:
+		value = deleted
+		c.field.SetBool(deleted)
+	}
+	return value
+}
+
+func softDeleteContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string, softDelete softDeleteColumn) (*DBResult, error) {
+	_, pkMap := StructToDbMap(data)
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return nil, errors.New("unknown database type")
+	}
+	previous := reflect.New(softDelete.field.Type()).Elem()
+	previous.Set(softDelete.field)
+	setClause := fmt.Sprintf("%s=%s", softDelete.key, GetPlaceHolder(0, dbType))
+	values := []any{softDelete.set(true)}
+	versionKey, versionField, versioned := taggedField(data, "version")
+	version := intFieldValue(versionField)
+	if versioned {
+		SqlSafe(&versionKey)
+		setClause += fmt.Sprintf(",%s=%s+1", versionKey, versionKey)
+	}
+	where, whereValues, err := MapForSqlWhere(pkMap, len(values), dbType)
+	if err != nil {
+		softDelete.field.Set(previous)
+		return nil, err
+	}
+	values = append(values, whereValues...)
+	if versioned {
+		where += fmt.Sprintf(" AND %s=%s", versionKey, GetPlaceHolder(len(values), dbType))
+		values = append(values, version)
+	}
+	clause, clauseValues := softDelete.notDeletedClause(len(values), dbType)
+	where += " " + clause
+	values = append(values, clauseValues...)
+
+	SqlSafe(&table)
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET %s WHERE 1=1 %s`, table, setClause, where)
+	result, err := ExecContext(ctx, conn, sqlStatement, values...)
+	if err != nil || result.RowsAffected == 0 {
+		softDelete.field.Set(previous)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if versioned {
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("%w: %s, %v", ErrStaleObject, table, pkMap)
+		}
+		setIntField(versionField, version+1)
+	}
+	return result, nil
+}
+
+// HardDelete - delete the row of data from table, even if data has a softdelete field.
+// Like Delete, the version field is checked if there is one.
+func HardDelete[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
+	return HardDeleteContext(context.Background(), toDBContext(conn), data, table)
+}
+
+// HardDeleteContext - same as HardDelete, with a context.
+func HardDeleteContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	return deleteWithHooks(ctx, conn, data, func() (*DBResult, error) {
+		result, err := hardDeleteContext(ctx, conn, data, table)
+		return result, queryTable(err, "HardDelete", table)
+	})
+}
+
+func hardDeleteContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	_, pkMap := StructToDbMap(data)
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return nil, errors.New("unknown database type")
+	}
+	where, whereValues, err := MapForSqlWhere(pkMap, 0, dbType)
+	if err != nil {
+		return nil, err
+	}
+	versionKey, versionField, versioned := taggedField(data, "version")
+	if versioned {
+		SqlSafe(&versionKey)
+		where += fmt.Sprintf(" AND %s=%s", versionKey, GetPlaceHolder(len(whereValues), dbType))
+		whereValues = append(whereValues, intFieldValue(versionField))
+	}
+	SqlSafe(&table)
+	sqlStatement := fmt.Sprintf(`DELETE FROM %s WHERE 1=1 %s`, table, where)
+	result, err := ExecContext(ctx, conn, sqlStatement, whereValues...)
+	if err == nil && versioned && result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: %s, %v", ErrStaleObject, table, pkMap)
+	}
+	return result, err
+}
+
+// Restore - clear the softdelete field of a row marked as deleted, so Retrieve finds it again.
+// The field of data is cleared as well. Restoring a row that isn't deleted affects no rows.
+func Restore[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
+	return RestoreContext(context.Background(), toDBContext(conn), data, table)
+}
+
+func RestoreContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	softDelete, ok, err := softDeleteField(data)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("no softdelete field")
+	}
+	_, pkMap := StructToDbMap(data)
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return nil, errors.New("unknown database type")
+	}
+	previous := reflect.New(softDelete.field.Type()).Elem()
+	previous.Set(softDelete.field)
+	setClause := fmt.Sprintf("%s=%s", softDelete.key, GetPlaceHolder(0, dbType))
+	values := []any{softDelete.set(false)}
+	where, whereValues, err := MapForSqlWhere(pkMap, len(values), dbType)
+	if err != nil {
+		softDelete.field.Set(previous)
+		return nil, err
+	}
+	values = append(values, whereValues...)
+	clause, clauseValues := softDelete.deletedClause(len(values), dbType)
+	where += " " + clause
+	values = append(values, clauseValues...)
+
+	SqlSafe(&table)
+	sqlStatement := fmt.Sprintf(`UPDATE %s SET %s WHERE 1=1 %s`, table, setClause, where)
+	result, err := ExecContext(ctx, conn, sqlStatement, values...)
+	if err != nil {
+		softDelete.field.Set(previous)
+		return nil, queryTable(err, "Restore", table)
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestSoftDelete(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	type Account struct {
+		Id        int        `db:"ID" pk:"true"`
+		Name      string     `db:"NAME"`
+		DeletedAt *time.Time `db:"DELETED_AT" softdelete:"true"`
+	}
+	_, err = Exec(db, "CREATE TABLE account (ID INTEGER PRIMARY KEY, NAME TEXT, DELETED_AT DATETIME)")
+	assert.NoError(t, err)
+
+	account := Account{Id: 1, Name: "Alpha"}
+	_, err = Create(db, &account, "account")
+	assert.NoError(t, err)
+
+	result, err := Delete(db, &account, "account")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	assert.NotNil(t, account.DeletedAt)
+
+	// deleting again finds no live row
+	result, err = Delete(db, &account, "account")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.RowsAffected)
+
+	resultMaps, err := QueryToMaps(db, "SELECT COUNT(*) AS c FROM account")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), resultMaps[0]["c"])
+
+	stored := Account{Id: 1}
+	assert.Error(t, Retrieve(db, &stored, "account"))
+	assert.NoError(t, RetrieveContext(IncludeDeleted(context.Background()), db, &stored, "account"))
+	assert.Equal(t, "Alpha", stored.Name)
+	assert.NotNil(t, stored.DeletedAt)
+
+	result, err = Restore(db, &stored, "account")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	assert.Nil(t, stored.DeletedAt)
+	assert.NoError(t, Retrieve(db, &stored, "account"))
+
+	result, err = HardDelete(db, &stored, "account")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	assert.Error(t, RetrieveContext(IncludeDeleted(context.Background()), db, &stored, "account"))
+}
+
+func TestSoftDeleteBool(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	type Flagged struct {
+		Id      int  `db:"ID" pk:"true"`
+		Deleted bool `db:"DELETED" softdelete:"true"`
+	}
+	_, err = Exec(db, "CREATE TABLE flagged (ID INTEGER PRIMARY KEY, DELETED BOOLEAN)")
+	assert.NoError(t, err)
+	_, err = Exec(db, "INSERT INTO flagged (ID) VALUES (1), (2)")
+	assert.NoError(t, err)
+
+	flagged := Flagged{Id: 1}
+	assert.NoError(t, Retrieve(db, &flagged, "flagged"))
+	_, err = Delete(db, &flagged, "flagged")
+	assert.NoError(t, err)
+	assert.True(t, flagged.Deleted)
+	assert.Error(t, Retrieve(db, &Flagged{Id: 1}, "flagged"))
+	assert.NoError(t, Retrieve(db, &Flagged{Id: 2}, "flagged"))
+
+	_, err = Restore(db, &flagged, "flagged")
+	assert.NoError(t, err)
+	assert.False(t, flagged.Deleted)
+	assert.NoError(t, Retrieve(db, &Flagged{Id: 1}, "flagged"))
+
+	type Invalid struct {
+		Id      int    `db:"ID" pk:"true"`
+		Deleted string `db:"DELETED" softdelete:"true"`
+	}
+	_, err = Delete(db, &Invalid{Id: 1}, "flagged")
+	assert.Error(t, err)
+}
@@ -0,0 +1,314 @@
+package gosqlcrud
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Preparer is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Preparer interface {
+	DBContext
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// defaultStmtCacheSize is the size of a StmtCache created with a size <= 0.
+const defaultStmtCacheSize = 128
+
+// StmtCache is a connection that prepares every statement run through it once, and reuses the
+// prepared statement for the same SQL text. The package functions build the same SQL for the
+// same struct and table, so Create, Retrieve, Update and Delete are prepared once per table.
+// The least recently used statement is evicted when the cache is full. It's closed once the
+// statements running it are done, and on a *sql.Conn or *sql.Tx, whose prepared statements
+// can't outlive rows still open on them, once the rows it returned are closed. A *sql.Row of a
+// *sql.Conn or *sql.Tx must be scanned before the next statement runs on the cache. Transactions
+// begun by WithTx on the cache reuse its statements. The statements it hasn't cached yet are
+// prepared on the transaction once and cached for the rest of the transaction.
+type StmtCache struct {
+	conn   Preparer
+	parent *StmtCache // the cache of the connection conn was begun on, if conn is a transaction of WithTx
+	size   int
+
+	mutex  sync.Mutex
+	stmts  map[string]*list.Element
+	lru    *list.List // of *cachedStmt, most recently used first
+	closed bool
+	// evicted holds the evicted statements of a *sql.Conn or *sql.Tx until their rows are closed
+	evicted []*cachedStmt
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+	// users counts the statements running stmt, an evicted stmt is closed when it drops to 0
+	users   int
+	evicted bool
+	// rows are the rows stmt returned on a *sql.Conn or *sql.Tx, which may still be open
+	rows []*sql.Rows
+}
+
+// done reports whether nobody runs cs and the rows it returned are closed. The mutex of its
+// cache is held.
+func (cs *cachedStmt) done() bool {
+	cs.rows = slices.DeleteFunc(cs.rows, rowsClosed)
+	return cs.users == 0 && len(cs.rows) == 0
+}
+
+// rowsClosed reports whether rows are closed, Columns only fails once they are.
+func rowsClosed(rows *sql.Rows) bool {
+	_, err := rows.Columns()
+	return err != nil
+}
+
+type unpreparedKey struct{}
+
+// unprepared returns a context that makes a StmtCache run statements without preparing them,
+// for statements that never run twice, like the savepoints of WithTx.
+func unprepared(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unpreparedKey{}, true)
+}
+
+func isUnprepared(ctx context.Context) bool {
+	v, _ := ctx.Value(unpreparedKey{}).(bool)
+	return v
+}
+
+// NewStmtCache - wrap conn to cache up to size prepared statements, 128 if size <= 0.
+// Close the cache to close its statements.
+func NewStmtCache(conn Preparer, size int) *StmtCache {
+	if size <= 0 {
+		size = defaultStmtCacheSize
+	}
+	return &StmtCache{
+		conn:  conn,
+		size:  size,
+		stmts: map[string]*list.Element{},
+		lru:   list.New(),
+	}
+}
+
+// Close - close all cached statements. Statements run afterwards are not prepared.
+func (c *StmtCache) Close() error {
+	if c.parent != nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	var errs []error
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		cs := e.Value.(*cachedStmt)
+		// statements still running are closed by release
+		cs.evicted = true
+		if cs.users == 0 {
+			errs = append(errs, cs.stmt.Close())
+		}
+	}
+	for _, cs := range c.evicted {
+		errs = append(errs, cs.stmt.Close())
+	}
+	c.evicted = nil
+	c.lru.Init()
+	clear(c.stmts)
+	return errors.Join(errs...)
+}
+
+// acquire returns the cached statement of query, nil if the cache is closed or ctx is
+// unprepared. The statement isn't closed until it's passed to release.
+func (c *StmtCache) acquire(ctx context.Context, query string) (*cachedStmt, error) {
+	if isUnprepared(ctx) {
+		return nil, nil
+	}
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, nil
+	}
+	c.sweep()
+	if e, ok := c.stmts[query]; ok {
+		c.lru.MoveToFront(e)
+		cs := e.Value.(*cachedStmt)
+		cs.users++
+		c.mutex.Unlock()
+		return cs, nil
+	}
+	c.mutex.Unlock()
+
+	// prepare without holding the lock, another goroutine may prepare the same query meanwhile
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		stmt.Close()
+		return nil, nil
+	}
+	if e, ok := c.stmts[query]; ok {
+		stmt.Close()
+		c.lru.MoveToFront(e)
+		cs := e.Value.(*cachedStmt)
+		cs.users++
+		return cs, nil
+	}
+	cs := &cachedStmt{query: query, stmt: stmt, users: 1}
+	c.stmts[query] = c.lru.PushFront(cs)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Remove(c.lru.Back()).(*cachedStmt)
+		delete(c.stmts, oldest.query)
+		oldest.evicted = true
+		if oldest.users == 0 {
+			c.retire(oldest)
+		}
+	}
+	return cs, nil
+}
+
+// prepare prepares query on the connection of c. A transaction of WithTx binds the statement
+// of its parent cache if there is one. Otherwise the statement is prepared on the transaction,
+// preparing it on the parent connection would need a second connection, which may not be free
+// while the transaction holds one.
+func (c *StmtCache) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	if c.parent == nil {
+		return c.conn.PrepareContext(ctx, query)
+	}
+	tx := c.conn.(*sql.Tx)
+	if cs := c.parent.lookup(query); cs != nil {
+		defer c.parent.release(cs)
+		return tx.StmtContext(ctx, cs.stmt), nil
+	}
+	return tx.PrepareContext(ctx, query)
+}
+
+// release ends a use of cs returned by acquire, and closes cs if it was evicted meanwhile.
+func (c *StmtCache) release(cs *cachedStmt) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cs.users--
+	if cs.evicted && cs.users == 0 {
+		c.retire(cs)
+	}
+}
+
+// retire closes an evicted statement nobody runs. Statements of a *sql.DB are closed at once,
+// database/sql keeps them open until their rows are closed. Those of a *sql.Conn or *sql.Tx
+// are closed once the rows they returned are. c.mutex is held.
+func (c *StmtCache) retire(cs *cachedStmt) {
+	if _, ok := c.conn.(*sql.DB); ok || c.closed {
+		cs.stmt.Close()
+		return
+	}
+	c.evicted = append(c.evicted, cs)
+	c.sweep()
+}
+
+// sweep closes the evicted statements whose rows are closed. c.mutex is held.
+func (c *StmtCache) sweep() {
+	c.evicted = slices.DeleteFunc(c.evicted, func(cs *cachedStmt) bool {
+		if !cs.done() {
+			return false
+		}
+		cs.stmt.Close()
+		return true
+	})
+}
+
+// track records rows returned by cs on a *sql.Conn or *sql.Tx, cs isn't closed until they are.
+func (c *StmtCache) track(cs *cachedStmt, rows *sql.Rows) {
+	if _, ok := c.conn.(*sql.DB); ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cs.rows = append(slices.DeleteFunc(cs.rows, rowsClosed), rows)
+}
+
+// lookup returns the statement of query if it's cached, nil otherwise. A statement returned
+// must be passed to release.
+func (c *StmtCache) lookup(query string) *cachedStmt {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.stmts[query]; ok {
+		c.lru.MoveToFront(e)
+		cs := e.Value.(*cachedStmt)
+		cs.users++
+		return cs
+	}
+	return nil
+}
+
+func (c *StmtCache) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.QueryContext(context.Background(), query, args...)
+}
+
+func (c *StmtCache) Exec(query string, args ...any) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+func (c *StmtCache) QueryRow(query string, args ...any) *sql.Row {
+	return c.QueryRowContext(context.Background(), query, args...)
+}
+
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	cs, err := c.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if cs == nil {
+		return c.conn.QueryContext(ctx, query, args...)
+	}
+	defer c.release(cs)
+	rows, err := cs.stmt.QueryContext(ctx, args...)
+	if err == nil {
+		c.track(cs, rows)
+	}
+	return rows, err
+}
+
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	cs, err := c.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if cs == nil {
+		return c.conn.ExecContext(ctx, query, args...)
+	}
+	defer c.release(cs)
+	return cs.stmt.ExecContext(ctx, args...)
+}
+
+func (c *StmtCache) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	cs, err := c.acquire(ctx, query)
+	if err != nil || cs == nil {
+		// the unprepared query reports the error, if it wasn't transient
+		return c.conn.QueryRowContext(ctx, query, args...)
+	}
+	defer c.release(cs)
+	return cs.stmt.QueryRowContext(ctx, args...)
+}
+
+func (c *StmtCache) unwrap() DBContext {
+	return c.conn
+}
+
+func (c *StmtCache) withTx(tx *sql.Tx) DBContext {
+	if c.conn == tx {
+		// a savepoint in the transaction of c
+		return c
+	}
+	parent := c
+	if c.parent != nil {
+		parent = c.parent
+	}
+	return &StmtCache{
+		conn:   tx,
+		parent: parent,
+		size:   parent.size,
+		stmts:  map[string]*list.Element{},
+		lru:    list.New(),
+	}
+}
@@ -0,0 +1,215 @@
+package gosqlcrud
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestStmtCache(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = Exec(db, "CREATE TABLE cache_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+
+	type CacheTest struct {
+		Id   int    `db:"ID" pk:"true"`
+		Name string `db:"NAME"`
+	}
+
+	cache := NewStmtCache(db, 2)
+	assert.Equal(t, SQLite, GetDbType(cache))
+	for i := 1; i <= 3; i++ {
+		_, err = Create(cache, &CacheTest{Id: i, Name: "name"}, "cache_test")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, cache.lru.Len())
+
+	record := CacheTest{Id: 2}
+	err = Retrieve(cache, &record, "cache_test")
+	assert.NoError(t, err)
+	assert.Equal(t, "name", record.Name)
+	assert.Equal(t, 2, cache.lru.Len())
+
+	// the least recently used statement, the insert, is evicted and closed
+	var insert *sql.Stmt
+	for e := cache.lru.Front(); e != nil; e = e.Next() {
+		if e.Value.(*cachedStmt).query != "SELECT * FROM cache_test WHERE ID=?" {
+			insert = e.Value.(*cachedStmt).stmt
+		}
+	}
+	maps, err := QueryToMaps(cache, "SELECT * FROM cache_test ORDER BY ID")
+	assert.NoError(t, err)
+	assert.Len(t, maps, 3)
+	assert.Equal(t, 2, cache.lru.Len())
+	_, err = insert.Exec(4, "name")
+	assert.Error(t, err)
+	_, cached := cache.stmts["SELECT * FROM cache_test ORDER BY ID"]
+	assert.True(t, cached)
+
+	// transactions of WithTx reuse the cached statements, also through a logger
+	var buf bytes.Buffer
+	logged := WithLogger(cache, slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), false)
+	_, err = QueryToMaps(cache, "SELECT * FROM cache_test WHERE NAME=?", "name")
+	assert.NoError(t, err)
+	err = WithTx(logged, nil, func(tx DB) error {
+		if _, err := QueryToMaps(tx, "SELECT * FROM cache_test WHERE NAME=?", "name"); err != nil {
+			return err
+		}
+		txCache, ok := tx.(*LoggedDB).conn.(*StmtCache)
+		assert.True(t, ok)
+		assert.Equal(t, cache, txCache.parent)
+		if _, err := Update(tx, &CacheTest{Id: 1, Name: "updated"}, "cache_test"); err != nil {
+			return err
+		}
+		// statements the cache doesn't have are prepared once per transaction
+		for i := 10; i < 15; i++ {
+			if _, err := Create(tx, &CacheTest{Id: i, Name: "tx"}, "cache_test"); err != nil {
+				return err
+			}
+		}
+		assert.Equal(t, 2, txCache.lru.Len())
+		_, cached := txCache.stmts["INSERT INTO cache_test (ID,NAME) VALUES (?,?)"]
+		assert.True(t, cached)
+		return WithTx(tx, nil, func(tx DB) error {
+			assert.Same(t, txCache, tx.(*LoggedDB).conn)
+			_, err := HardDelete(tx, &CacheTest{Id: 3}, "cache_test")
+			return err
+		})
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "UPDATE cache_test")
+	assert.Contains(t, buf.String(), "SAVEPOINT")
+	_, cached = cache.stmts["DELETE FROM cache_test WHERE ID=?"]
+	assert.False(t, cached)
+
+	// closing the cache closes its statements, later statements aren't prepared
+	var stmt *sql.Stmt
+	for _, e := range cache.stmts {
+		stmt = e.Value.(*cachedStmt).stmt
+	}
+	assert.NoError(t, cache.Close())
+	assert.Equal(t, 0, cache.lru.Len())
+	_, err = stmt.Query()
+	assert.Error(t, err)
+	maps, err = QueryToMaps(cache, "SELECT * FROM cache_test WHERE ID<10 ORDER BY ID")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "updated"}, {"id": int64(2), "name": "name"}}, maps)
+	assert.Equal(t, 0, cache.lru.Len())
+}
+
+func TestStmtCacheConcurrent(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = Exec(db, "CREATE TABLE cache_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+	_, err = Exec(db, "INSERT INTO cache_test (ID, NAME) VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+	assert.NoError(t, err)
+
+	// statements evicted by other goroutines are only closed once they are done
+	cache := NewStmtCache(db, 2)
+	defer cache.Close()
+	var wg sync.WaitGroup
+	errs := make(chan error, 64*20)
+	for i := range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 20 {
+				maps, err := QueryToMaps(cache, fmt.Sprintf("SELECT * FROM cache_test WHERE ID<=%d", (i+j)%5))
+				if err == nil && len(maps) != min((i+j)%5, 3) {
+					err = fmt.Errorf("%d rows", len(maps))
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.LessOrEqual(t, cache.lru.Len(), 2)
+}
+
+func TestStmtCacheBounded(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = Exec(db, "CREATE TABLE cache_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+	_, err = Exec(db, "INSERT INTO cache_test (ID, NAME) VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+	assert.NoError(t, err)
+
+	conn, err := db.Conn(t.Context())
+	assert.NoError(t, err)
+	defer conn.Close()
+	cache := NewStmtCache(conn, 4)
+	defer cache.Close()
+	for i := range 50 {
+		_, err := QueryToMaps(cache, fmt.Sprintf("SELECT * FROM cache_test WHERE ID<=%d", i))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4, cache.lru.Len())
+	assert.Empty(t, cache.evicted)
+
+	// an evicted statement is closed once its rows are
+	rows, err := cache.QueryContext(t.Context(), "SELECT ID FROM cache_test ORDER BY ID")
+	assert.NoError(t, err)
+	for i := range 4 {
+		_, err := QueryToMaps(cache, fmt.Sprintf("SELECT * FROM cache_test WHERE ID>%d", i))
+		assert.NoError(t, err)
+	}
+	assert.Len(t, cache.evicted, 1)
+	var ids []int
+	for rows.Next() {
+		var id int
+		assert.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, []int{1, 2, 3}, ids)
+	_, err = QueryToMaps(cache, "SELECT * FROM cache_test")
+	assert.NoError(t, err)
+	assert.Empty(t, cache.evicted)
+
+	// savepoints aren't cached, the statements of a transaction are closed once evicted
+	dbCache := NewStmtCache(db, 4)
+	defer dbCache.Close()
+	err = WithTx(dbCache, nil, func(tx DB) error {
+		txCache := tx.(*StmtCache)
+		var nest func(depth int) error
+		nest = func(depth int) error {
+			if depth == 50 {
+				return nil
+			}
+			return WithTx(tx, nil, func(tx DB) error {
+				if _, err := QueryToMaps(tx, fmt.Sprintf("SELECT * FROM cache_test WHERE ID<>%d", depth)); err != nil {
+					return err
+				}
+				return nest(depth + 1)
+			})
+		}
+		if err := nest(0); err != nil {
+			return err
+		}
+		assert.Equal(t, 4, txCache.lru.Len())
+		for query := range txCache.stmts {
+			assert.NotContains(t, query, "SAVEPOINT")
+		}
+		assert.Empty(t, txCache.evicted)
+		return nil
+	})
+	assert.NoError(t, err)
+}
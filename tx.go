@@ -0,0 +1,231 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// TxOptions configures WithTx. The zero value begins a transaction with the default isolation
+// level and doesn't retry.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// MaxRetries is the number of times the transaction is run again after it failed with a
+	// transient error, such as a deadlock or a serialization failure.
+	MaxRetries int
+	// Backoff returns how long to wait before the retry-th retry, starting at 1. Defaults to
+	// ExponentialBackoff(10*time.Millisecond, time.Second).
+	Backoff func(retry int) time.Duration
+}
+
+// TransientErrorDialect is implemented by dialects that can tell if an error is transient,
+// i.e. the transaction that failed with it may succeed when run again.
+type TransientErrorDialect interface {
+	Dialect
+	IsTransient(err error) bool
+}
+
+// SavepointDialect is implemented by dialects whose savepoint statements differ from the
+// standard SAVEPOINT, RELEASE SAVEPOINT and ROLLBACK TO SAVEPOINT.
+type SavepointDialect interface {
+	Dialect
+	// SavepointSql returns the statement creating the savepoint name.
+	SavepointSql(name string) string
+	// ReleaseSavepointSql returns the statement releasing the savepoint name, "" if the
+	// database has none.
+	ReleaseSavepointSql(name string) string
+	// RollbackToSavepointSql returns the statement rolling back to the savepoint name.
+	RollbackToSavepointSql(name string) string
+}
+
+// savepointSeq numbers the savepoints, so nested savepoints have distinct names.
+var savepointSeq atomic.Int64
+
+// ExponentialBackoff - a backoff for TxOptions that doubles from base up to max, with jitter.
+func ExponentialBackoff(base time.Duration, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		d := max
+		if retry < 32 && base<<(retry-1) < max {
+			d = base << (retry - 1)
+		}
+		if d <= 1 {
+			return d
+		}
+		return d/2 + rand.N(d/2)
+	}
+}
+
+// WithTx - run fn in a transaction on db. The transaction is committed if fn returns nil, and
+// rolled back if fn returns an error or panics. If opts.MaxRetries is set, fn is run again in
+// a new transaction when the transaction failed with an error the dialect of db reports as
+// transient, so fn must not have side effects outside the transaction.
+func WithTx[T DB](db T, opts *TxOptions, fn func(tx DB) error) error {
+	return WithTxContext(context.Background(), toDBContext(db), opts, func(tx DBContext) error {
+		return fn(tx.(DB))
+	})
+}
+
+// WithTxContext - same as WithTx, with a context. db is a *sql.DB or a *sql.Conn, possibly
+// wrapped with WithLogger or NewStmtCache, in which case tx is wrapped the same way.
+//
+// If db is a *sql.Tx, fn runs in a savepoint of that transaction instead: the savepoint is
+// released if fn returns nil, and rolled back to if fn fails, leaving the outer transaction
+// usable. opts are ignored then, as a savepoint can't be retried on its own.
+func WithTxContext[T DBContext](ctx context.Context, db T, opts *TxOptions, fn func(tx DBContext) error) error {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	if tx, ok := unwrapConn(db).(*sql.Tx); ok {
+		return runSavepoint(ctx, db, tx, fn)
+	}
+	beginner, ok := unwrapConn(db).(TxBeginner)
+	if !ok {
+		return errors.New("db can't begin a transaction")
+	}
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(10*time.Millisecond, time.Second)
+	}
+	for retry := 1; ; retry++ {
+		err := runTx(ctx, db, beginner, opts, fn)
+		if err == nil || retry > opts.MaxRetries || !isTransient(ctx, db, err) {
+			return err
+		}
+		timer := time.NewTimer(backoff(retry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func runTx(ctx context.Context, db DBContext, beginner TxBeginner, opts *TxOptions, fn func(tx DBContext) error) (err error) {
+	tx, err := BeginTx(ctx, beginner, &sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  opts.ReadOnly,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(wrapLike(db, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func runSavepoint(ctx context.Context, db DBContext, tx *sql.Tx, fn func(tx DBContext) error) (err error) {
+	conn := wrapLike(db, tx)
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return errors.New("unknown database type")
+	}
+	name := fmt.Sprintf("gosqlcrud_sp_%d", savepointSeq.Add(1))
+	savepoint, release, rollback := savepointSql(dbType, name)
+	// every savepoint has its own name, caching its statements would only evict others
+	ctx = unprepared(ctx)
+	if _, err := ExecContext(ctx, conn, savepoint); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			ExecContext(ctx, conn, rollback)
+			panic(p)
+		}
+	}()
+	if err := fn(conn); err != nil {
+		if _, rbErr := ExecContext(ctx, conn, rollback); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	if release == "" {
+		return nil
+	}
+	_, err = ExecContext(ctx, conn, release)
+	return err
+}
+
+// savepointSql returns the statements creating, releasing and rolling back to the savepoint name.
+func savepointSql(dbType DbType, name string) (savepoint string, release string, rollback string) {
+	if d, ok := dbType.Dialect().(SavepointDialect); ok {
+		return d.SavepointSql(name), d.ReleaseSavepointSql(name), d.RollbackToSavepointSql(name)
+	}
+	return "SAVEPOINT " + name, "RELEASE SAVEPOINT " + name, "ROLLBACK TO SAVEPOINT " + name
+}
+
+// wrapLike wraps tx with the connection wrappers of db, like its logger or statement cache.
+func wrapLike(db any, tx *sql.Tx) DBContext {
+	if w, ok := unwrapAdapter(db).(wrappedConn); ok {
+		return w.withTx(tx)
+	}
+	return tx
+}
+
+func isTransient(ctx context.Context, db DBContext, err error) bool {
+	d, ok := GetDbTypeContext(ctx, db).Dialect().(TransientErrorDialect)
+	return ok && d.IsTransient(err)
+}
+
+// IsTransient reports SQLITE_BUSY, including its extended codes.
+func (d sqliteDialect) IsTransient(err error) bool {
+	code, ok := errorNumber(err)
+	return ok && code&0xff == 5
+}
+
+// IsTransient reports deadlocks, error 1213.
+func (d mysqlDialect) IsTransient(err error) bool {
+	code, ok := errorNumber(err)
+	return ok && code == 1213
+}
+
+// IsTransient reports serialization failures and deadlocks, SQLSTATE 40001 and 40P01.
+func (d postgresDialect) IsTransient(err error) bool {
+	state, ok := errorSQLState(err)
+	return ok && (state == "40001" || state == "40P01")
+}
+
+func (d sqlServerDialect) SavepointSql(name string) string {
+	return "SAVE TRANSACTION " + name
+}
+
+// ReleaseSavepointSql returns "", SQL Server savepoints last until the transaction ends.
+func (d sqlServerDialect) ReleaseSavepointSql(name string) string {
+	return ""
+}
+
+func (d sqlServerDialect) RollbackToSavepointSql(name string) string {
+	return "ROLLBACK TRANSACTION " + name
+}
+
+func (d oracleDialect) SavepointSql(name string) string {
+	return "SAVEPOINT " + name
+}
+
+// ReleaseSavepointSql returns "", Oracle savepoints last until the transaction ends.
+func (d oracleDialect) ReleaseSavepointSql(name string) string {
+	return ""
+}
+
+func (d oracleDialect) RollbackToSavepointSql(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// IsTransient reports deadlock victims, error 1205.
+func (d sqlServerDialect) IsTransient(err error) bool {
+	code, ok := errorNumber(err)
+	return ok && code == 1205
+}
@@ -0,0 +1,209 @@
+package gosqlcrud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestWithTx(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = Exec(db, "CREATE TABLE tx_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+	count := func() int64 {
+		maps, err := QueryToMaps(db, "SELECT COUNT(*) AS c FROM tx_test")
+		assert.NoError(t, err)
+		return maps[0]["c"].(int64)
+	}
+
+	err = WithTx(db, nil, func(tx DB) error {
+		_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (1, 'committed')")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count())
+
+	errRollback := errors.New("roll back")
+	err = WithTx(db, nil, func(tx DB) error {
+		_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (2, 'rolled back')")
+		assert.NoError(t, err)
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+	assert.Equal(t, int64(1), count())
+
+	assert.PanicsWithValue(t, "boom", func() {
+		WithTx(db, nil, func(tx DB) error {
+			_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (3, 'panicked')")
+			assert.NoError(t, err)
+			panic("boom")
+		})
+	})
+	assert.Equal(t, int64(1), count())
+
+	// errors that aren't transient are not retried
+	runs := 0
+	err = WithTx(db, &TxOptions{MaxRetries: 3}, func(tx DB) error {
+		runs++
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+	assert.Equal(t, 1, runs)
+}
+
+func TestWithTxRetry(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", dsn)
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = Exec(db, "CREATE TABLE tx_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+
+	// another connection holds the write lock, so the first run fails with SQLITE_BUSY
+	other, err := sql.Open("sqlite", dsn)
+	assert.NoError(t, err)
+	defer other.Close()
+	lock, err := other.Conn(context.Background())
+	assert.NoError(t, err)
+	_, err = lock.ExecContext(context.Background(), "BEGIN IMMEDIATE")
+	assert.NoError(t, err)
+
+	runs := 0
+	var retries []int
+	opts := &TxOptions{
+		MaxRetries: 3,
+		Backoff: func(retry int) time.Duration {
+			retries = append(retries, retry)
+			_, err := lock.ExecContext(context.Background(), "COMMIT")
+			assert.NoError(t, err)
+			return time.Millisecond
+		},
+	}
+	err = WithTx(db, opts, func(tx DB) error {
+		runs++
+		_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (1, 'retried')")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, []int{1}, retries)
+	assert.NoError(t, lock.Close())
+
+	maps, err := QueryToMaps(db, "SELECT NAME FROM tx_test")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"name": "retried"}}, maps)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	for retry, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 4: 50 * time.Millisecond, 100: 50 * time.Millisecond} {
+		d := backoff(retry)
+		assert.GreaterOrEqual(t, d, max/2)
+		assert.Less(t, d, max)
+	}
+}
+
+type pgError struct{ Code string }
+
+func (e *pgError) Error() string { return e.Code }
+
+type mysqlError struct{ Number uint16 }
+
+func (e *mysqlError) Error() string { return "mysql" }
+
+type mssqlError struct{ Number int32 }
+
+func (e mssqlError) Error() string         { return "mssql" }
+func (e mssqlError) SQLErrorNumber() int32 { return e.Number }
+
+func TestIsTransient(t *testing.T) {
+	wrap := func(err error) error {
+		return errors.Join(errors.New("first"), fmt.Errorf("wrapped: %w", err))
+	}
+	assert.True(t, postgresDialect{}.IsTransient(wrap(&pgError{Code: "40001"})))
+	assert.True(t, postgresDialect{}.IsTransient(&pgError{Code: "40P01"}))
+	assert.False(t, postgresDialect{}.IsTransient(&pgError{Code: "23505"}))
+	assert.True(t, mysqlDialect{}.IsTransient(wrap(&mysqlError{Number: 1213})))
+	assert.False(t, mysqlDialect{}.IsTransient(&mysqlError{Number: 1062}))
+	assert.True(t, sqlServerDialect{}.IsTransient(wrap(mssqlError{Number: 1205})))
+	assert.False(t, sqlServerDialect{}.IsTransient(mssqlError{Number: 2627}))
+	assert.False(t, sqliteDialect{}.IsTransient(errors.New("database is locked")))
+}
+
+func TestNestedTx(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = Exec(db, "CREATE TABLE tx_test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+
+	errInner := errors.New("inner failed")
+	err = WithTx(db, nil, func(tx DB) error {
+		if _, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (1, 'outer')"); err != nil {
+			return err
+		}
+		// a failed inner transaction only rolls back its own changes
+		err := WithTx(tx, nil, func(tx DB) error {
+			_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (2, 'inner failed')")
+			assert.NoError(t, err)
+			return errInner
+		})
+		assert.ErrorIs(t, err, errInner)
+		assert.Panics(t, func() {
+			WithTx(tx, nil, func(tx DB) error {
+				_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (3, 'inner panicked')")
+				assert.NoError(t, err)
+				panic("boom")
+			})
+		})
+		return WithTx(tx, nil, func(tx DB) error {
+			_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (4, 'inner')")
+			if err != nil {
+				return err
+			}
+			return WithTx(tx, nil, func(tx DB) error {
+				_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (5, 'innermost')")
+				return err
+			})
+		})
+	})
+	assert.NoError(t, err)
+
+	maps, err := QueryToMaps(db, "SELECT NAME FROM tx_test ORDER BY ID")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"name": "outer"}, {"name": "inner"}, {"name": "innermost"}}, maps)
+
+	// a successful inner transaction is still rolled back with the outer one
+	err = WithTx(db, nil, func(tx DB) error {
+		err := WithTx(tx, nil, func(tx DB) error {
+			_, err := Exec(tx, "INSERT INTO tx_test (ID, NAME) VALUES (6, 'inner')")
+			return err
+		})
+		assert.NoError(t, err)
+		return errInner
+	})
+	assert.ErrorIs(t, err, errInner)
+	maps, err = QueryToMaps(db, "SELECT COUNT(*) AS c FROM tx_test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), maps[0]["c"])
+}
+
+func TestSavepointSql(t *testing.T) {
+	savepoint, release, rollback := savepointSql(PostgreSQL, "sp")
+	assert.Equal(t, []string{"SAVEPOINT sp", "RELEASE SAVEPOINT sp", "ROLLBACK TO SAVEPOINT sp"}, []string{savepoint, release, rollback})
+	savepoint, release, rollback = savepointSql(SQLServer, "sp")
+	assert.Equal(t, []string{"SAVE TRANSACTION sp", "", "ROLLBACK TRANSACTION sp"}, []string{savepoint, release, rollback})
+	savepoint, release, rollback = savepointSql(Oracle, "sp")
+	assert.Equal(t, []string{"SAVEPOINT sp", "", "ROLLBACK TO SAVEPOINT sp"}, []string{savepoint, release, rollback})
+}
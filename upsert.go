@@ -0,0 +1,46 @@
+package gosqlcrud
+
+import (
+	"context"
+	"errors"
+)
+
+// Upsert - insert data into table, or update the existing row with the same primary key.
+// The fields tagged pk:"true" are the conflict target.
+func Upsert[T DB, S any](conn T, data *S, table string) (*DBResult, error) {
+	return UpsertContext(context.Background(), toDBContext(conn), data, table)
+}
+
+func UpsertContext[T DBContext, S any](ctx context.Context, conn T, data *S, table string) (*DBResult, error) {
+	nonPkMap, pkMap := StructToDbMap(data)
+	dbType := GetDbTypeContext(ctx, conn)
+	if dbType == Unknown {
+		return nil, errors.New("unknown database type")
+	}
+	sqlStatement, values, err := UpsertSql(table, nonPkMap, pkMap, dbType)
+	if err != nil {
+		return nil, err
+	}
+	result, err := ExecContext(ctx, conn, sqlStatement, values...)
+	return result, queryTable(err, "Upsert", table)
+}
+
+// UpsertSql - build the insert-or-update statement of dbType for table. pkMap is the conflict
+// target, the columns of nonPkMap are updated when the row exists. The columns of pkMap come
+// first, each in the order of its DbMap.
+func UpsertSql(table string, nonPkMap DbMap, pkMap DbMap, dbType DbType) (sqlStatement string, values []any, err error) {
+	if dbType.Dialect() == nil {
+		return "", nil, errors.New("unknown database type")
+	}
+	if len(pkMap) == 0 {
+		return "", nil, errors.New("upsert requires at least one primary key field")
+	}
+	values = append(pkMap.Values(), nonPkMap.Values()...)
+
+	SqlSafe(&table)
+	sqlStatement, err = dbType.Dialect().UpsertSql(table, pkMap.Keys(), nonPkMap.Keys())
+	if err != nil {
+		return "", nil, err
+	}
+	return sqlStatement, values, nil
+}
@@ -0,0 +1,76 @@
+package gosqlcrud
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestUpsert(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = Exec(db, "CREATE TABLE test (ID INTEGER PRIMARY KEY, NAME TEXT)")
+	assert.NoError(t, err)
+
+	name := "Alpha"
+	data := Test{Id: 1, Name: &name}
+	result, err := Upsert(db, &data, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	name = "Beta"
+	result, err = Upsert(db, &data, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	resultStruct := Test{Id: 1}
+	err = Retrieve(db, &resultStruct, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Beta", *resultStruct.Name)
+
+	data.Name = nil
+	result, err = Upsert(db, &data, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.RowsAffected)
+}
+
+func TestUpsertSql(t *testing.T) {
+	nonPkMap := SortedDbMap(map[string]any{"NAME": "Alpha", "AGE": 3})
+	pkMap := DbMap{{Key: "ID", Value: 1}}
+
+	sqlStatement, values, err := UpsertSql("test", nonPkMap, pkMap, SQLite)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO test (ID,AGE,NAME) VALUES (?,?,?) ON CONFLICT (ID) DO UPDATE SET AGE=excluded.AGE,NAME=excluded.NAME", sqlStatement)
+	assert.Equal(t, []any{1, 3, "Alpha"}, values)
+
+	sqlStatement, _, err = UpsertSql("test", nonPkMap, pkMap, PostgreSQL)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO test (ID,AGE,NAME) VALUES ($1,$2,$3) ON CONFLICT (ID) DO UPDATE SET AGE=excluded.AGE,NAME=excluded.NAME", sqlStatement)
+
+	sqlStatement, _, err = UpsertSql("test", nil, pkMap, PostgreSQL)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO test (ID) VALUES ($1) ON CONFLICT (ID) DO NOTHING", sqlStatement)
+
+	sqlStatement, _, err = UpsertSql("test", nonPkMap, pkMap, MySQL)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO test (ID,AGE,NAME) VALUES (?,?,?) ON DUPLICATE KEY UPDATE AGE=VALUES(AGE),NAME=VALUES(NAME)", sqlStatement)
+
+	sqlStatement, _, err = UpsertSql("test", nonPkMap, DbMap{{Key: "ID", Value: 1}, {Key: "KIND", Value: "x"}}, SQLServer)
+	assert.NoError(t, err)
+	assert.Equal(t, "MERGE INTO test AS target USING (SELECT @p1 AS ID,@p2 AS KIND,@p3 AS AGE,@p4 AS NAME) AS source ON (target.ID=source.ID AND target.KIND=source.KIND)"+
+		" WHEN MATCHED THEN UPDATE SET target.AGE=source.AGE,target.NAME=source.NAME"+
+		" WHEN NOT MATCHED THEN INSERT (ID,KIND,AGE,NAME) VALUES (source.ID,source.KIND,source.AGE,source.NAME);", sqlStatement)
+
+	sqlStatement, _, err = UpsertSql("test", nonPkMap, pkMap, Oracle)
+	assert.NoError(t, err)
+	assert.Equal(t, "MERGE INTO test target USING (SELECT :1 AS ID,:2 AS AGE,:3 AS NAME FROM DUAL) source ON (target.ID=source.ID)"+
+		" WHEN MATCHED THEN UPDATE SET target.AGE=source.AGE,target.NAME=source.NAME"+
+		" WHEN NOT MATCHED THEN INSERT (ID,AGE,NAME) VALUES (source.ID,source.AGE,source.NAME)", sqlStatement)
+
+	_, _, err = UpsertSql("test", nonPkMap, nil, SQLite)
+	assert.Error(t, err)
+}